@@ -0,0 +1,113 @@
+package backupscmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/andornaut/gog/internal/link"
+	"github.com/andornaut/gog/internal/output"
+	"github.com/andornaut/gog/internal/prompt"
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// Cmd implements ./gog backups
+var Cmd = &cobra.Command{
+	Use:          "backups [command]",
+	Short:        "Manage .gog backups of files which were replaced by links",
+	SilenceUsage: true,
+}
+
+var repositoryFlag string
+
+var clean = &cobra.Command{
+	Use:                   "clean",
+	Short:                 "Delete .gog backups",
+	Args:                  cobra.NoArgs,
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		backups, err := backups()
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			return nil
+		}
+		for _, b := range backups {
+			output.Println(b.BackupPath)
+		}
+		if !prompt.Confirm(fmt.Sprintf("Delete %d backups?", len(backups))) {
+			return nil
+		}
+		for _, b := range backups {
+			if err := link.RemoveBackup(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var list = &cobra.Command{
+	Use:                   "list",
+	Short:                 "Print the paths of .gog backups",
+	Args:                  cobra.NoArgs,
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		backups, err := backups()
+		if err != nil {
+			return err
+		}
+		for _, b := range backups {
+			output.Emit(output.Event{
+				Action:     output.ActionBackup,
+				ExtPath:    b.ExtPath,
+				IntPath:    b.IntPath,
+				BackupPath: b.BackupPath,
+				Repository: filepath.Base(b.RepoPath),
+			}, b.BackupPath)
+		}
+		return nil
+	},
+}
+
+// backups returns the backups of the files in the repository given by
+// --repository, or in all repositories
+func backups() ([]link.Backup, error) {
+	names := []string{repositoryFlag}
+	if repositoryFlag == "" {
+		var err error
+		if names, err = repository.List(); err != nil {
+			return nil, err
+		}
+	}
+
+	var backups []link.Backup
+	seen := make(map[string]bool)
+	for _, name := range names {
+		repoPath, err := repository.RootPath(name)
+		if err != nil {
+			return nil, err
+		}
+		b, err := link.Backups(repoPath, repoPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, backup := range b {
+			// Repositories which contain the same file share its backup
+			if !seen[backup.BackupPath] {
+				seen[backup.BackupPath] = true
+				backups = append(backups, backup)
+			}
+		}
+	}
+	return backups, nil
+}
+
+func init() {
+	clean.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository (default: all repositories)")
+	clean.Flags().BoolVarP(&prompt.AssumeYes, "yes", "y", false, "do not ask for confirmation")
+	list.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository (default: all repositories)")
+	Cmd.AddCommand(clean, list)
+}
@@ -1,15 +1,43 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/spf13/cobra"
 
+	"github.com/andornaut/gog/cmd/backupscmd"
 	"github.com/andornaut/gog/cmd/repositorycmd"
 	"github.com/andornaut/gog/internal/git"
 	"github.com/andornaut/gog/internal/link"
+	"github.com/andornaut/gog/internal/output"
+	"github.com/andornaut/gog/internal/privileged"
+	"github.com/andornaut/gog/internal/prompt"
 	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/secret"
 )
 
-var repositoryFlag string
+var (
+	allFlag             bool
+	dryRunFlag          bool
+	encryptFlag         bool
+	escalateFlag        string
+	failFastFlag        bool
+	homeFlag            string
+	importFromFlag      string
+	keepGoingFlag       bool
+	noRollbackFlag      bool
+	onConflictFlag      string
+	outputFlag          string
+	pruneFlag           bool
+	repositoryFlag      string
+	restoreBackupFlag   bool
+	targetRootFlag      string
+	repositoryNamesFlag []string
+)
 
 var add = &cobra.Command{
 	Use:                   "add [paths...]",
@@ -22,16 +50,199 @@ var add = &cobra.Command{
 			return err
 		}
 		paths := cleanPaths(args)
-		if err := repository.AddPaths(repoPath, paths); err != nil {
+		addPaths := repository.AddPaths
+		if encryptFlag {
+			addPaths = repository.AddEncryptedPaths
+		}
+		if err := addPaths(repoPath, paths); err != nil {
+			return err
+		}
+		return linkResults(link.Link(repoPath, paths))
+	},
+}
+
+var adopt = &cobra.Command{
+	Use:                   "adopt [paths...]",
+	Short:                 "Copy files that replaced links back into a repository, and link them again",
+	Long:                  "Editors that save files by renaming a new file over the old one replace links with regular files. Adopts all such files in the repository if no paths are given.",
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		repoPath, err := repoPath()
+		if err != nil {
 			return err
 		}
-		return link.Link(repoPath, paths)
+		intPaths := []string{repoPath}
+		if len(args) > 0 {
+			intPaths = intPaths[:0]
+			for _, p := range cleanPaths(args) {
+				intPaths = append(intPaths, repository.ToInternalPath(repoPath, p))
+			}
+		}
+
+		for _, intPath := range intPaths {
+			statuses, err := link.Status(repoPath, intPath)
+			if err != nil {
+				return err
+			}
+			for _, s := range statuses {
+				if s.State != link.StateReplaced {
+					continue
+				}
+				d, err := link.Diff(repoPath, s.IntPath)
+				if err != nil {
+					return err
+				}
+				if d != nil {
+					printDiff(*d)
+				}
+				if !dryRunFlag && !prompt.Confirm(fmt.Sprintf("Copy %s into the repository?", s.ExtPath)) {
+					continue
+				}
+				if err := link.Adopt(repoPath, s.IntPath); err != nil {
+					return linkResults(err)
+				}
+			}
+		}
+		return linkResults(nil)
 	},
 }
 
 var apply = &cobra.Command{
 	Use:                   "apply",
 	Short:                 "Link a repository's contents to the filesystem",
+	Long:                  "When several repositories contain the same file, it is linked to the one with the highest priority",
+	Args:                  cobra.NoArgs,
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		if err := link.SetConflictPolicy(onConflictFlag); err != nil {
+			return err
+		}
+		if !allFlag && len(repositoryNamesFlag) < 2 {
+			if len(repositoryNamesFlag) == 1 {
+				repositoryFlag = repositoryNamesFlag[0]
+			}
+			repoPath, err := repoPath()
+			if err != nil {
+				return err
+			}
+			return applyRepositories([]string{repoPath}, func() error {
+				return link.Dir(repoPath, repoPath)
+			})
+		}
+
+		repoPaths, err := repoPaths()
+		if err != nil {
+			return err
+		}
+		return applyRepositories(repoPaths, func() error {
+			return link.Repositories(repoPaths)
+		})
+	},
+}
+
+var diff = &cobra.Command{
+	Use:                   "diff [paths...]",
+	Short:                 "Show the differences between repository files and the files at their external paths",
+	Long:                  "Compares the content that gog would link, render or decrypt with what currently exists at each external path. Compares the whole repository if no paths are given. Exits with a nonzero status if any file differs.",
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		repoPath, err := repoPath()
+		if err != nil {
+			return err
+		}
+		intPaths := []string{repoPath}
+		if len(args) > 0 {
+			intPaths = intPaths[:0]
+			for _, p := range cleanPaths(args) {
+				intPaths = append(intPaths, repository.ToInternalPath(repoPath, p))
+			}
+		}
+
+		changed := 0
+		for _, intPath := range intPaths {
+			intFileInfo, err := os.Stat(intPath)
+			if err != nil {
+				return err
+			}
+			var diffs []link.FileDiff
+			if intFileInfo.IsDir() {
+				diffs, err = link.DiffDir(repoPath, intPath)
+			} else {
+				var d *link.FileDiff
+				if d, err = link.Diff(repoPath, intPath); d != nil {
+					diffs = append(diffs, *d)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			for _, d := range diffs {
+				printDiff(d)
+			}
+			changed += len(diffs)
+		}
+		if changed > 0 {
+			return fmt.Errorf("%d files differ", changed)
+		}
+		return nil
+	},
+}
+
+var import_ = &cobra.Command{
+	Use:                   "import dir",
+	Short:                 "Copy the files of a GNU stow, chezmoi or yadm directory into a repository",
+	Long:                  "Files are converted to gog's layout, and their permissions are recorded. Run `gog apply` afterwards to link them.",
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		repoPath, err := repoPath()
+		if err != nil {
+			return err
+		}
+		dir, err := normalizePath(args[0])
+		if err != nil {
+			return err
+		}
+		return repository.Import(repoPath, importFromFlag, dir)
+	},
+}
+
+var keygen = &cobra.Command{
+	Use:                   "keygen",
+	Short:                 "Create the age identity with which files are encrypted, and print its public key",
+	Long:                  "The identity is stored outside of the repositories, in $GOG_IDENTITY_FILE or ~/.config/gog/identity.txt. Add the public keys of other machines to the recipients setting of a repository's .gog.toml.",
+	Args:                  cobra.NoArgs,
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		publicKey, err := secret.GenerateIdentity()
+		if err != nil {
+			return err
+		}
+		e := output.Event{Action: output.ActionIdentity, ExtPath: secret.IdentityFilePath(), PublicKey: publicKey}
+		output.Emit(e, publicKey)
+		return nil
+	},
+}
+
+var restore = &cobra.Command{
+	Use:                   "restore [paths...]",
+	Short:                 "Replace links with the .gog backups of the files that they replaced",
+	Long:                  "The files remain in the repository",
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		repoPath, err := repoPath()
+		if err != nil {
+			return err
+		}
+		return linkResults(link.Restore(repoPath, cleanPaths(args)))
+	},
+}
+
+var status = &cobra.Command{
+	Use:                   "status",
+	Short:                 "Print the link state of a repository's files",
+	Long:                  "Exits with a nonzero status if any file is not linked",
 	Args:                  cobra.NoArgs,
 	DisableFlagsInUseLine: true,
 	RunE: func(c *cobra.Command, args []string) error {
@@ -39,7 +250,66 @@ var apply = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return link.Dir(repoPath, repoPath)
+		statuses, err := link.Status(repoPath, repoPath)
+		if err != nil {
+			return err
+		}
+
+		outOfSync := 0
+		for _, s := range statuses {
+			if !s.InSync() {
+				outOfSync++
+			}
+			printStatus(repoPath, s)
+		}
+		if outOfSync > 0 {
+			return fmt.Errorf("%d of %d files are out of sync", outOfSync, len(statuses))
+		}
+		return nil
+	},
+}
+
+var sync = &cobra.Command{
+	Use:                   "sync [paths...]",
+	Short:                 "Copy changes between a repository and the copies of its files",
+	Long:                  "Only files that are linked in copy mode are synced. Syncs the whole repository if no paths are given.",
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		repoPath, err := repoPath()
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return linkResults(link.SyncDir(repoPath, repoPath))
+		}
+		return linkResults(link.Sync(repoPath, cleanPaths(args)))
+	},
+}
+
+var textconv = &cobra.Command{
+	Use:                   "textconv path",
+	Short:                 "Print the decrypted content of an encrypted file",
+	Long:                  "git runs this command to show the changes to encrypted files in `gog git diff`",
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		content, err := secret.DecryptFile(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(content)
+		return err
+	},
+}
+
+var undo = &cobra.Command{
+	Use:                   "undo",
+	Short:                 "Undo the changes of the last apply",
+	Long:                  "Removes the links and directories that were created, and restores the files that were backed up or replaced",
+	Args:                  cobra.NoArgs,
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return link.Undo()
 	},
 }
 
@@ -58,6 +328,17 @@ var git_ = &cobra.Command{
 	},
 }
 
+var privileged_ = &cobra.Command{
+	Use:                   privileged.HelperCommand + " operation [arguments...]",
+	Short:                 "Change a file as root on behalf of another gog command",
+	Hidden:                true,
+	DisableFlagParsing:    true,
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return privileged.Run(args, os.Stdin)
+	},
+}
+
 var remove = &cobra.Command{
 	Use:                   "remove [paths...]",
 	Short:                 "Remove files or directories from a repository",
@@ -77,19 +358,181 @@ var remove = &cobra.Command{
 	},
 }
 
+// applyRepositories runs the repositories' pre_apply hooks, links their files
+// using the given function, prunes stale links and then runs the post_apply hooks
+func applyRepositories(repoPaths []string, linkFunc func() error) error {
+	for _, repoPath := range repoPaths {
+		c, err := repository.LoadConfig(repoPath)
+		if err != nil {
+			return err
+		}
+		if err := repository.RunHook(repoPath, "pre_apply", c.Hooks.PreApply); err != nil {
+			return err
+		}
+	}
+
+	if err := link.BeginJournal(); err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+	stop := interruptOnSignal()
+	err := linkFunc()
+	if err == nil {
+		err = prune(repoPaths)
+	}
+	stop()
+	if closeErr := link.CloseJournal(); closeErr != nil {
+		err = errors.Join(err, closeErr)
+	}
+	if err = linkResults(err); err != nil {
+		if noRollbackFlag {
+			// The files that could be linked are kept, and can be undone by `gog undo`
+			return err
+		}
+		// Every file that could not be linked was reported, so no file is left half-applied
+		output.Println("Rolling back...")
+		if rollbackErr := link.Rollback(); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return fmt.Errorf("%w (the changes were rolled back)", err)
+	}
+
+	for _, repoPath := range repoPaths {
+		c, err := repository.LoadConfig(repoPath)
+		if err != nil {
+			return err
+		}
+		if err := repository.RunHook(repoPath, "post_apply", c.Hooks.PostApply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interruptOnSignal interrupts linking when gog receives SIGINT or SIGTERM,
+// so that the changes can be rolled back, until stop is called
+func interruptOnSignal() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-signals; ok {
+			link.Interrupt()
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
+
+// linkResults returns err, unless it was caused by a file that could not be
+// linked, in which case it returns a summary of all files
+func linkResults(err error) error {
+	var fileErr *link.FileError
+	if err != nil && !errors.As(err, &fileErr) {
+		return err
+	}
+	_, err = link.Results()
+	return err
+}
+
+// prune removes links to files which have been deleted from the given
+// repositories after asking for confirmation
+func prune(repoPaths []string) error {
+	if !pruneFlag {
+		return nil
+	}
+
+	var staleLinks []link.StaleLink
+	for _, repoPath := range repoPaths {
+		s, err := link.StaleLinks(repoPath)
+		if err != nil {
+			return err
+		}
+		staleLinks = append(staleLinks, s...)
+	}
+	if len(staleLinks) == 0 {
+		return nil
+	}
+
+	output.Println("Stale links:")
+	for _, s := range staleLinks {
+		output.Println(fmt.Sprintf("  %s -> %s", s.ExtPath, s.IntPath))
+	}
+	if !dryRunFlag && !prompt.Confirm(fmt.Sprintf("Remove %d stale links?", len(staleLinks))) {
+		return nil
+	}
+	for _, s := range staleLinks {
+		restoreBackup := s.BackupPath != "" && (dryRunFlag || prompt.Confirm(fmt.Sprintf("Restore backup %s?", s.BackupPath)))
+		if err := link.Prune(s, restoreBackup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Cmd implements the root ./gog command
 var Cmd = &cobra.Command{
 	Use:              "gog [command]",
 	Short:            "Link files to Git repositories",
 	SilenceUsage:     true,
 	TraverseChildren: true,
+	PersistentPreRunE: func(c *cobra.Command, args []string) error {
+		link.DryRun = dryRunFlag
+		link.FailFast = failFastFlag
+		link.RestoreBackups = restoreBackupFlag
+		repository.DryRun = dryRunFlag
+		privileged.DataDir = repository.BaseDir
+		if err := repository.SetTarget(targetRootFlag, homeFlag); err != nil {
+			return err
+		}
+		if err := privileged.SetCommand(escalateFlag); err != nil {
+			return err
+		}
+		return output.SetFormat(outputFlag)
+	},
 }
 
 func init() {
 	// Cannot add --repository as a persistent flag, because this breaks passthrough to `git`
 	add.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
-	apply.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
+	add.Flags().BoolVar(&encryptFlag, "encrypt", false, "encrypt files in the repository, and decrypt them instead of linking them")
+	adopt.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	adopt.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
+	adopt.Flags().BoolVarP(&prompt.AssumeYes, "yes", "y", false, "do not ask for confirmation")
+	diff.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
+	apply.Flags().BoolVarP(&allFlag, "all", "a", false, "apply all repositories")
+	apply.Flags().BoolVarP(&link.Interactive, "interactive", "i", false, "ask how to resolve each conflict with an existing file")
+	apply.Flags().BoolVar(&noRollbackFlag, "no-rollback", false, "keep the changes that were made if any file cannot be linked")
+	apply.Flags().StringVar(&onConflictFlag, "on-conflict", "", "resolve conflicts with existing files without asking: backup, overwrite, skip, adopt or fail (default: backup, or overwrite if backups are disabled)")
+	apply.Flags().BoolVar(&pruneFlag, "prune", false, "remove links to files which have been deleted from the repository")
+	apply.Flags().BoolVarP(&prompt.AssumeYes, "yes", "y", false, "do not ask for confirmation")
+	apply.Flags().StringSliceVarP(&repositoryNamesFlag, "repository", "r", nil, "names of repositories, in order of decreasing priority")
+	import_.Flags().StringVar(&importFromFlag, "from", "", "layout of the directory: stow, chezmoi or yadm")
+	import_.MarkFlagRequired("from")
+	import_.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	import_.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
 	remove.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
+	remove.Flags().BoolVar(&restoreBackupFlag, "restore-backup", false, "restore .gog backups instead of copying the removed files")
+	restore.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	restore.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
+	status.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
+	sync.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	sync.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
+	undo.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	add.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	apply.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	remove.Flags().BoolVarP(&dryRunFlag, "dry-run", "n", false, "print what would be done without changing anything")
+	for _, c := range []*cobra.Command{add, apply, sync} {
+		c.Flags().BoolVar(&failFastFlag, "fail-fast", false, "stop at the first file that cannot be linked")
+		c.Flags().BoolVar(&keepGoingFlag, "keep-going", false, "report files that cannot be linked and continue with the next file (default)")
+		c.MarkFlagsMutuallyExclusive("fail-fast", "keep-going")
+	}
+	for _, c := range []*cobra.Command{apply, remove, status} {
+		c.Flags().StringVar(&homeFlag, "home", "", "home directory to which $HOME is expanded, e.g. /home/dev (default: the current user's)")
+		c.Flags().StringVar(&targetRootFlag, "target-root", "", "directory to which files are applied instead of /, e.g. a mounted image")
+	}
+	Cmd.PersistentFlags().StringVar(&escalateFlag, "escalate", os.Getenv("GOG_ESCALATE"), "change files which you are not permitted to change, e.g. in /etc, as root with: sudo or pkexec ($GOG_ESCALATE)")
+	Cmd.PersistentFlags().StringVar(&outputFlag, "output", string(output.Text), "output format: text, json or ndjson")
 	Cmd.Flags().StringVarP(&repositoryFlag, "repository", "r", "", "name of repository")
-	Cmd.AddCommand(add, apply, git_, remove, repositorycmd.Cmd)
+	Cmd.AddCommand(add, adopt, apply, backupscmd.Cmd, diff, git_, import_, keygen, privileged_, remove, repositorycmd.Cmd, restore, status, sync, textconv, undo)
 }
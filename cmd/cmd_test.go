@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/link"
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// TestApplyRollsBackWhenAnyFileFails verifies every file is attempted, and the
+// files that were linked are then rolled back unless --no-rollback is given
+func TestApplyRollsBackWhenAnyFileFails(t *testing.T) {
+	originalBaseDir := repository.BaseDir
+	repository.BaseDir = t.TempDir()
+	defer func() {
+		// Unsaved states would otherwise be saved into the removed directory
+		state.Discard()
+		repository.BaseDir = originalBaseDir
+	}()
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	repoPath := filepath.Join(repository.BaseDir, "dotfiles")
+	for _, p := range []string{"a.txt", filepath.Join("locked", "b.txt"), "c.txt"} {
+		intPath := filepath.Join(repoPath, "$HOME", p)
+		if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(intPath, []byte(p), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = repoPath
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to initialize git repo: %v", err)
+	}
+
+	// The directory of b.txt cannot be created, even by root, because its parent is a file
+	if err := os.WriteFile(filepath.Join(testHome, "locked"), nil, 0644); err != nil {
+		t.Fatalf("Failed to create blocking file: %v", err)
+	}
+	linkRepository := func() error {
+		return link.Dir(repoPath, repoPath)
+	}
+	isLinked := func(name string) bool {
+		linkDest, _ := os.Readlink(filepath.Join(testHome, name))
+		return linkDest == filepath.Join(repoPath, "$HOME", name)
+	}
+
+	if err := applyRepositories([]string{repoPath}, linkRepository); err == nil {
+		t.Fatal("applyRepositories() should fail when a file cannot be linked")
+	}
+	for _, name := range []string{"a.txt", "c.txt"} {
+		if _, err := os.Lstat(filepath.Join(testHome, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been rolled back", name)
+		}
+	}
+
+	noRollbackFlag = true
+	defer func() { noRollbackFlag = false }()
+	if err := applyRepositories([]string{repoPath}, linkRepository); err == nil {
+		t.Fatal("applyRepositories() should fail when a file cannot be linked")
+	}
+	for _, name := range []string{"a.txt", "c.txt"} {
+		if !isLinked(name) {
+			t.Errorf("%s should remain linked with --no-rollback", name)
+		}
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/andornaut/gog/internal/link"
+	"github.com/andornaut/gog/internal/output"
 	"github.com/andornaut/gog/internal/repository"
 )
 
@@ -18,7 +21,8 @@ func cleanPaths(paths []string) []string {
 		}
 		normalized, err := normalizePath(p)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: skipping invalid path %q: %v\n", p, err)
+			output.EmitError(output.Event{Action: output.ActionSkipped, ExtPath: p, Reason: err.Error()},
+				fmt.Sprintf("Warning: skipping invalid path %q: %v", p, err))
 			continue
 		}
 		cleanedPaths = append(cleanedPaths, normalized)
@@ -43,6 +47,71 @@ func repoPath() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	fmt.Println("Repository:", filepath.Base(repoPath))
+	output.Println("Repository:", filepath.Base(repoPath))
 	return repoPath, nil
 }
+
+// repoPaths returns the paths of the repositories selected by --all or
+// --repository, in order of decreasing priority. Repositories named by
+// --repository keep the order in which they were given.
+func repoPaths() ([]string, error) {
+	names := repositoryNamesFlag
+	if allFlag {
+		var err error
+		if names, err = repository.List(); err != nil {
+			return nil, err
+		}
+	}
+
+	repoPaths := make([]string, 0, len(names))
+	for _, name := range names {
+		repoPath, err := repository.RootPath(name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(repoPaths, repoPath) {
+			repoPaths = append(repoPaths, repoPath)
+		}
+	}
+	if allFlag {
+		repository.SortByPriority(repoPaths)
+	}
+
+	repoNames := make([]string, 0, len(repoPaths))
+	for _, repoPath := range repoPaths {
+		repoNames = append(repoNames, filepath.Base(repoPath))
+	}
+	output.Println("Repositories:", strings.Join(repoNames, ", "))
+	return repoPaths, nil
+}
+
+func printDiff(d link.FileDiff) {
+	e := output.Event{
+		Action:  output.ActionDiffered,
+		ExtPath: d.ExtPath,
+		IntPath: d.IntPath,
+		Diff:    d.Diff,
+		Reason:  d.Reason,
+	}
+	output.Emit(e, strings.TrimSuffix(d.String(), "\n"))
+}
+
+func printStatus(repoPath string, s link.FileStatus) {
+	e := output.Event{
+		Action:     output.ActionStatus,
+		ExtPath:    s.ExtPath,
+		IntPath:    s.IntPath,
+		Repository: filepath.Base(repoPath),
+		State:      string(s.State),
+	}
+	text := fmt.Sprintf("%-16s %s", s.State, s.ExtPath)
+	if s.Target != "" && s.State != link.StateLinked {
+		e.Reason = fmt.Sprintf("linked to %s", s.Target)
+		text = fmt.Sprintf("%s -> %s", text, s.Target)
+	}
+	if s.Drift != "" {
+		e.Reason = strings.TrimPrefix(e.Reason+"; "+s.Drift, "; ")
+		text = fmt.Sprintf("%s (%s)", text, s.Drift)
+	}
+	output.Emit(e, text)
+}
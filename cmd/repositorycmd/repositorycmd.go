@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/andornaut/gog/internal/output"
 	"github.com/andornaut/gog/internal/repository"
 )
 
@@ -34,7 +35,7 @@ var add = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Added repository: %s\n", repoPath)
+		output.Emit(repositoryEvent(output.ActionAdded, repoPath), fmt.Sprintf("Added repository: %s", repoPath))
 		return nil
 	},
 }
@@ -51,11 +52,11 @@ var getDefault = &cobra.Command{
 			return err
 		}
 
+		msg := filepath.Base(repoPath)
 		if isPath {
-			fmt.Println(repoPath)
-		} else {
-			fmt.Println(filepath.Base(repoPath))
+			msg = repoPath
 		}
+		output.Emit(repositoryEvent(output.ActionRepository, repoPath), msg)
 		return nil
 	},
 }
@@ -70,11 +71,13 @@ var list = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		for _, msg := range names {
+		for _, name := range names {
+			repoPath := filepath.Join(repository.BaseDir, name)
+			msg := name
 			if isPath {
-				msg = filepath.Join(repository.BaseDir, msg)
+				msg = repoPath
 			}
-			fmt.Println(msg)
+			output.Emit(repositoryEvent(output.ActionRepository, repoPath), msg)
 		}
 		return nil
 	},
@@ -91,11 +94,15 @@ var remove = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Removed repository: %s\n", repoPath)
+		output.Emit(repositoryEvent(output.ActionRemoved, repoPath), fmt.Sprintf("Removed repository: %s", repoPath))
 		return nil
 	},
 }
 
+func repositoryEvent(action, repoPath string) output.Event {
+	return output.Event{Action: action, Repository: filepath.Base(repoPath), RepositoryPath: repoPath}
+}
+
 func init() {
 	getDefault.Flags().BoolVarP(&isPath, "path", "p", false, "print the path instead of the name")
 	list.Flags().BoolVarP(&isPath, "path", "p", false, "print paths instead of names")
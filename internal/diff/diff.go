@@ -0,0 +1,208 @@
+// Package diff compares the contents of files line by line
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines that are printed around each change
+const context = 3
+
+type op int
+
+const (
+	opEqual op = iota
+	opDelete
+	opInsert
+)
+
+type edit struct {
+	op   op
+	line string
+	// aIndex and bIndex are the 0-based line numbers in a and b before the edit
+	aIndex, bIndex int
+}
+
+// IsBinary returns true if the given content is not text
+func IsBinary(b []byte) bool {
+	if len(b) > 8000 {
+		b = b[:8000]
+	}
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+// Unified returns a unified diff from a to b, which are named aName and bName,
+// or an empty string if they are identical
+func Unified(aName, bName string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	if IsBinary(a) || IsBinary(b) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", aName, bName)
+	}
+
+	edits := compare(splitLines(a), splitLines(b))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for i := 0; i < len(edits); {
+		// Find the next change
+		for i < len(edits) && edits[i].op == opEqual {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		// Extend the hunk until the next run of unchanged lines that is too long to include
+		start := max(i-context, 0)
+		end := i
+		for end < len(edits) {
+			if edits[end].op != opEqual {
+				end++
+				continue
+			}
+			j := end
+			for j < len(edits) && edits[j].op == opEqual {
+				j++
+			}
+			if j == len(edits) || j-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = j
+		}
+		writeHunk(&sb, edits[start:end])
+		i = end
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, edits []edit) {
+	aLen, bLen := 0, 0
+	for _, e := range edits {
+		if e.op != opInsert {
+			aLen++
+		}
+		if e.op != opDelete {
+			bLen++
+		}
+	}
+	// Like diff(1), empty ranges start at the line before them
+	aStart, bStart := edits[0].aIndex+1, edits[0].bIndex+1
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, e := range edits {
+		switch e.op {
+		case opEqual:
+			sb.WriteString(" ")
+		case opDelete:
+			sb.WriteString("-")
+		case opInsert:
+			sb.WriteString("+")
+		}
+		sb.WriteString(e.line)
+		sb.WriteString("\n")
+	}
+}
+
+func splitLines(b []byte) []string {
+	s := string(b)
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// compare returns the shortest edit script from a to b, using Myers' algorithm
+func compare(a, b []string) []edit {
+	n, m := len(a), len(b)
+	switch {
+	case n == 0:
+		edits := make([]edit, 0, m)
+		for y, line := range b {
+			edits = append(edits, edit{op: opInsert, line: line, bIndex: y})
+		}
+		return edits
+	case m == 0:
+		edits := make([]edit, 0, n)
+		for x, line := range a {
+			edits = append(edits, edit{op: opDelete, line: line, aIndex: x})
+		}
+		return edits
+	}
+
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	// trace[d] holds the diagonals -d to d of v before step d, which are the only
+	// ones that backtracking reads, so memory grows with the number of edits
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Backtrack from the end of both inputs to find the path that was taken
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[d+prevK]
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{op: opEqual, line: a[x], aIndex: x, bIndex: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{op: opInsert, line: b[y], aIndex: x, bIndex: y})
+		} else {
+			x--
+			edits = append(edits, edit{op: opDelete, line: a[x], aIndex: x, bIndex: y})
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
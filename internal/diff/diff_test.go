@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestUnified verifies changes are printed in hunks with context
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n12\n13\n"
+	expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -8,5 +8,5 @@
+ 8
+ 9
+ 10
+-11
+ 12
++13
+`
+	if result := Unified("a", "b", []byte(a), []byte(b)); result != expected {
+		t.Errorf("Unified() =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+// TestUnifiedEdgeCases verifies identical, empty and binary inputs
+func TestUnifiedEdgeCases(t *testing.T) {
+	if result := Unified("a", "b", []byte("same\n"), []byte("same\n")); result != "" {
+		t.Errorf("Unified() of identical input = %q, want empty", result)
+	}
+
+	expected := "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+new\n+file\n"
+	if result := Unified("a", "b", nil, []byte("new\nfile\n")); result != expected {
+		t.Errorf("Unified() of new file = %q, want %q", result, expected)
+	}
+
+	if result := Unified("a", "b", []byte("text"), []byte("bin\x00ary")); !strings.HasPrefix(result, "Binary files a and b differ") {
+		t.Errorf("Unified() of binary input = %q", result)
+	}
+}
+
+// TestUnifiedLargeFiles verifies large files are compared in memory that grows
+// with the number of changes, rather than with the square of their length
+func TestUnifiedLargeFiles(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	a := []byte(sb.String())
+	b := []byte(strings.Replace(sb.String(), "line 5000\n", "changed\n", 1))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	missing := Unified("a", "b", nil, a)
+	changed := Unified("a", "b", a, b)
+	runtime.ReadMemStats(&after)
+
+	if !strings.HasPrefix(missing, "--- a\n+++ b\n@@ -0,0 +1,10000 @@\n+line 0\n") {
+		t.Errorf("Unified() of new file starts with %q", missing[:min(len(missing), 50)])
+	}
+	if !strings.Contains(changed, "-line 5000\n+changed\n") || strings.Count(changed, "@@ ") != 1 {
+		t.Errorf("Unified() of changed file = %q", changed)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 32<<20 {
+		t.Errorf("Unified() allocated %d MB, want less than 32 MB", allocated>>20)
+	}
+}
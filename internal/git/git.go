@@ -15,6 +15,13 @@ func Init(baseDir, repoPath string) error {
 	return Run(baseDir, "init", repoPath)
 }
 
+// HasCommits returns true if the given repository has at least one commit
+func HasCommits(baseDir string) bool {
+	cmd := exec.Command("git", "rev-parse", "--quiet", "--verify", "HEAD")
+	cmd.Dir = baseDir
+	return cmd.Run() == nil
+}
+
 // Is returns true if the given directory is a git repository
 func Is(baseDir string) bool {
 	cmd := exec.Command("git", "rev-parse", "--git-dir")
@@ -23,6 +30,15 @@ func Is(baseDir string) bool {
 	return err == nil
 }
 
+// Output runs a git command in a repository and returns its standard output
+func Output(baseDir string, arguments ...string) (string, error) {
+	cmd := exec.Command("git", arguments...)
+	cmd.Stderr = os.Stderr
+	cmd.Dir = baseDir
+	out, err := cmd.Output()
+	return string(out), err
+}
+
 // GitRun runs a git command in a repository
 func Run(baseDir string, arguments ...string) error {
 	cmd := exec.Command("git", arguments...)
@@ -0,0 +1,162 @@
+// Package ignore matches paths against patterns with the same syntax as
+// .gitignore files: https://git-scm.com/docs/gitignore#_pattern_format
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type pattern struct {
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher matches slash-separated relative paths against a list of patterns.
+// The last pattern that matches a path decides whether it is ignored.
+type Matcher struct {
+	patterns []pattern
+}
+
+// New returns a Matcher for the given pattern lines. Blank lines and comments are skipped.
+func New(lines []string) *Matcher {
+	m := &Matcher{}
+	for _, line := range lines {
+		if p, ok := parse(line); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m
+}
+
+// Load returns a Matcher for the given default pattern lines followed by the
+// lines of the file at p, which may not exist
+func Load(p string, defaults ...string) (*Matcher, error) {
+	lines := append([]string{}, defaults...)
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return New(lines), nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return New(lines), nil
+}
+
+// Match returns true if the given relative path, which is a directory if
+// isDir is true, is ignored. Like git, a path is ignored if any of its parent
+// directories are ignored, even if a later pattern would re-include it.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	relPath = strings.Trim(relPath, "/")
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(path.Join(parts[:i]...), true) {
+			return true
+		}
+	}
+	return m.match(relPath, isDir)
+}
+
+func (m *Matcher) match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.regex.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func parse(line string) (pattern, bool) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	p := pattern{}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// Escaped leading "!" or "#"
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return pattern{}, false
+	}
+
+	// Patterns which contain a slash are relative to the root, otherwise they match at any level
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "/**") && i+3 == len(line):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(line[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	regex, err := regexp.Compile(b.String())
+	if err != nil {
+		// Like git, skip invalid patterns
+		return pattern{}, false
+	}
+	p.regex = regex
+	return p, true
+}
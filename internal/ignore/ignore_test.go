@@ -0,0 +1,81 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMatch verifies gitignore pattern semantics
+func TestMatch(t *testing.T) {
+	m := New([]string{
+		"# Comment",
+		"",
+		"/README.md",
+		"docs/",
+		"*.sh",
+		"!keep.sh",
+		"$HOME/.cache/**",
+		"**/tmp",
+		"file[0-9].txt",
+		`\!important`,
+	})
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"README.md", false, true},
+		{"$HOME/README.md", false, false},
+		{"docs", true, true},
+		{"docs/index.md", false, true},
+		{"docs", false, false},
+		{"$HOME/project/docs/notes.txt", false, true},
+		{"install.sh", false, true},
+		{"$HOME/bin/install.sh", false, true},
+		{"$HOME/bin/keep.sh", false, false},
+		{"$HOME/.cache/foo/bar", false, true},
+		{"$HOME/.cache", true, false},
+		{"$HOME/a/b/tmp", false, true},
+		{"file1.txt", false, true},
+		{"fileA.txt", false, false},
+		{"!important", false, true},
+		{"$HOME/.bashrc", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if result := m.Match(tt.path, tt.isDir); result != tt.expected {
+				t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestLoadAppendsFileToDefaults verifies file patterns can override defaults
+func TestLoadAppendsFileToDefaults(t *testing.T) {
+	p := filepath.Join(t.TempDir(), ".gogignore")
+	if err := os.WriteFile(p, []byte("!/README.md\nCONTRIBUTING.md\n"), 0644); err != nil {
+		t.Fatalf("Failed to create ignore file: %v", err)
+	}
+
+	m, err := Load(p, "/README.md", "/LICENSE")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if m.Match("README.md", false) {
+		t.Error("README.md should be re-included by negation")
+	}
+	if !m.Match("LICENSE", false) || !m.Match("CONTRIBUTING.md", false) {
+		t.Error("LICENSE and CONTRIBUTING.md should be ignored")
+	}
+
+	m, err = Load(filepath.Join(t.TempDir(), "nonexistent"), "/LICENSE")
+	if err != nil {
+		t.Fatalf("Load() should not fail for a missing file: %v", err)
+	}
+	if !m.Match("LICENSE", false) {
+		t.Error("Default patterns should apply when the file does not exist")
+	}
+}
@@ -0,0 +1,45 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// Adopt copies a file that replaced a link back into the repository, and then links it again
+func Adopt(repoPath, intPath string) error {
+	extPath := repository.ToExternalPath(repoPath, intPath)
+	if !isReplaced(repoPath, intPath, extPath) {
+		return fail(intPath, extPath, fmt.Errorf("%s was not linked by gog, or was not replaced by a regular file", extPath))
+	}
+
+	if err := writeCopy(extPath, intPath); err != nil {
+		return fail(intPath, extPath, fmt.Errorf("failed to copy %s to %s: %w", extPath, intPath, err))
+	}
+	printAdopted(intPath, extPath)
+	if err := replace(extPath); err != nil {
+		return fail(intPath, extPath, fmt.Errorf("failed to remove %s: %w", extPath, err))
+	}
+
+	if mode(repoPath, intPath) == repository.ModeHardlink {
+		if err := hardlink(intPath, extPath); err != nil {
+			return fail(intPath, extPath, fmt.Errorf("failed to create hard link from %s to %s: %w", extPath, intPath, err))
+		}
+		printHardlinked(intPath, extPath)
+		s, err := state.Load(repoPath)
+		if err == nil {
+			err = recordHardlink(s, intPath, extPath)
+		}
+		if err != nil {
+			return fail(intPath, extPath, err)
+		}
+		return addToGit(repoPath, intPath)
+	}
+
+	if err := symlink(intPath, extPath); err != nil {
+		return fail(intPath, extPath, fmt.Errorf("failed to create symlink from %s to %s: %w", extPath, intPath, err))
+	}
+	printLinked(intPath, extPath)
+	return linked(repoPath, intPath, extPath)
+}
@@ -0,0 +1,74 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestAdoptReplacedLink verifies files that replaced links are copied into the repository and linked again
+func TestAdoptReplacedLink(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	intPath := filepath.Join(repoPath, "$HOME", ".vimrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("set number\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	extPath := filepath.Join(testHome, ".vimrc")
+
+	// A regular file that gog did not link is a conflict, rather than a replaced link
+	if err := os.WriteFile(extPath, []byte("local\n"), 0644); err != nil {
+		t.Fatalf("Failed to create external file: %v", err)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateConflict {
+		t.Errorf("State = %q, want %q", s.State, StateConflict)
+	}
+	if err := os.Remove(extPath); err != nil {
+		t.Fatalf("Failed to remove external file: %v", err)
+	}
+
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	// Save the file like an editor that renames a new file over the link
+	tmpPath := extPath + ".swp"
+	if err := os.WriteFile(tmpPath, []byte("set number\nset hidden\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Rename(tmpPath, extPath); err != nil {
+		t.Fatalf("Failed to replace link: %v", err)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateReplaced {
+		t.Errorf("State = %q, want %q", s.State, StateReplaced)
+	}
+
+	d, err := Diff(repoPath, intPath)
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	if d == nil || !strings.Contains(d.Diff, "\n+set hidden\n") {
+		t.Errorf("Diff() = %+v, want an added line", d)
+	}
+
+	if err := Adopt(repoPath, intPath); err != nil {
+		t.Fatalf("Adopt() failed: %v", err)
+	}
+	if content, _ := os.ReadFile(intPath); string(content) != "set number\nset hidden\n" {
+		t.Errorf("Repository file content = %q", content)
+	}
+	if target, err := os.Readlink(extPath); err != nil || target != intPath {
+		t.Errorf("%s should be linked to %s", extPath, intPath)
+	}
+}
@@ -0,0 +1,116 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andornaut/gog/internal/privileged"
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+var (
+	// RestoreBackups restores .gog backups instead of copying repository files when unlinking
+	RestoreBackups = false
+)
+
+// Backup is a .gog backup of a file or directory that was replaced by a link
+type Backup struct {
+	RepoPath   string
+	IntPath    string
+	ExtPath    string
+	BackupPath string
+}
+
+// Backups returns the .gog backups of the external paths of a repository
+// directory's contents, and of the files that were recorded below it, which
+// includes files that have since been deleted from the repository
+func Backups(repoPath, intPath string) ([]Backup, error) {
+	var backups []Backup
+	seen := make(map[string]bool)
+	add := func(b Backup) {
+		if _, err := os.Lstat(b.BackupPath); err == nil && !seen[b.BackupPath] {
+			seen[b.BackupPath] = true
+			backups = append(backups, b)
+		}
+	}
+	err := walk(repoPath, intPath, func(p string, _ os.FileInfo) error {
+		extPath := repository.ToExternalPath(repoPath, p)
+		add(Backup{RepoPath: repoPath, IntPath: p, ExtPath: extPath, BackupPath: backupPath(extPath)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range s.List() {
+		if e.IntPath != intPath && !strings.HasPrefix(e.IntPath, intPath+"/") {
+			continue
+		}
+		if e.BackupPath != "" {
+			add(Backup{RepoPath: repoPath, IntPath: e.IntPath, ExtPath: e.ExtPath, BackupPath: e.BackupPath})
+		}
+		add(Backup{RepoPath: repoPath, IntPath: e.IntPath, ExtPath: e.ExtPath, BackupPath: backupPath(e.ExtPath)})
+	}
+	return backups, nil
+}
+
+// RemoveBackup deletes a .gog backup
+func RemoveBackup(b Backup) error {
+	if !DryRun {
+		if err := privileged.RemoveAll(b.BackupPath); err != nil {
+			return err
+		}
+	}
+	printRemovedBackup(b.BackupPath)
+	return forgetBackup(b.RepoPath, b.ExtPath)
+}
+
+// Restore replaces the links to the given paths with their .gog backups
+func Restore(repoPath string, paths []string) error {
+	return syncLinks(repoPath, paths, RestoreDir, RestoreFile)
+}
+
+// RestoreDir replaces the links to a repository directory's files with their .gog backups
+func RestoreDir(repoPath, intPath string) error {
+	return walk(repoPath, intPath, func(p string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		return RestoreFile(repoPath, p)
+	})
+}
+
+// RestoreFile replaces the link to a repository file with its .gog backup.
+// The repository file is left unchanged.
+func RestoreFile(repoPath, intPath string) error {
+	extPath := repository.ToExternalPath(repoPath, intPath)
+	backupPath := backupPath(extPath)
+	if _, err := os.Lstat(backupPath); err != nil {
+		// Nothing to restore
+		return nil
+	}
+	if !isSymlink(extPath) || !isSameFile(extPath, intPath) {
+		return fail(intPath, extPath, fmt.Errorf("cannot restore %s: %s is not linked to the repository", backupPath, extPath))
+	}
+	if err := restoreBackup(backupPath, extPath); err != nil {
+		return err
+	}
+	return forget(repoPath, extPath)
+}
+
+func restoreBackup(backupPath, extPath string) error {
+	if err := removeLink(extPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", extPath, err)
+	}
+	if err := rename(backupPath, extPath); err != nil {
+		return fmt.Errorf("failed to restore %s to %s: %w", backupPath, extPath, err)
+	}
+	printRestored(backupPath, extPath)
+	return nil
+}
@@ -0,0 +1,114 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestRestoreFileReplacesLinkWithBackup verifies backups are found and restored
+func TestRestoreFileReplacesLinkWithBackup(t *testing.T) {
+	// Temporarily enable backups for this test
+	originalBackupDisabled := backupDisabled
+	backupDisabled = false
+	defer func() { backupDisabled = originalBackupDisabled }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	// Set up home directory for testing
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	// Create a test file in the repo
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err = os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err = os.WriteFile(intPath, []byte("new content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Create existing file at external path, which is backed up when linking
+	extPath := repository.ToExternalPath(repoPath, intPath)
+	existingContent := []byte("existing content")
+	if err = os.WriteFile(extPath, existingContent, 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+	if err = File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	backups, err := Backups(repoPath, repoPath)
+	if err != nil {
+		t.Fatalf("Backups() failed: %v", err)
+	}
+	if len(backups) != 1 || backups[0].BackupPath != backupPath(extPath) {
+		t.Fatalf("Backups() = %v, want backup of %q", backups, extPath)
+	}
+
+	if err = RestoreFile(repoPath, intPath); err != nil {
+		t.Fatalf("RestoreFile() failed: %v", err)
+	}
+
+	if isSymlink(extPath) {
+		t.Error("Path should no longer be a symlink")
+	}
+	content, err := os.ReadFile(extPath)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(content) != string(existingContent) {
+		t.Errorf("Restored file content = %q, want %q", content, existingContent)
+	}
+	if _, err := os.Stat(intPath); err != nil {
+		t.Errorf("Repository file should not be removed: %v", err)
+	}
+}
+
+// TestBackupsIncludesDeletedRepositoryFiles verifies the backups of files which
+// were deleted from the repository are found through the state
+func TestBackupsIncludesDeletedRepositoryFiles(t *testing.T) {
+	originalBackupDisabled := backupDisabled
+	backupDisabled = false
+	defer func() { backupDisabled = originalBackupDisabled }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+	testHome := t.TempDir()
+	defer repository.SetHomeDirForTest(repository.SetHomeDirForTest(testHome))
+
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("new content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	extPath := repository.ToExternalPath(repoPath, intPath)
+	if err := os.WriteFile(extPath, []byte("existing content"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if err := os.Remove(intPath); err != nil {
+		t.Fatalf("Failed to delete repository file: %v", err)
+	}
+
+	backups, err := Backups(repoPath, repoPath)
+	if err != nil {
+		t.Fatalf("Backups() failed: %v", err)
+	}
+	if len(backups) != 1 || backups[0].BackupPath != backupPath(extPath) || backups[0].IntPath != intPath {
+		t.Errorf("Backups() = %v, want backup of %q", backups, extPath)
+	}
+}
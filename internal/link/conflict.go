@@ -0,0 +1,123 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andornaut/gog/internal/prompt"
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// Policies which resolve a conflict with a file that exists at an external path
+const (
+	// ConflictBackup renames the existing file to its .gog backup
+	ConflictBackup = "backup"
+	// ConflictOverwrite removes the existing file
+	ConflictOverwrite = "overwrite"
+	// ConflictSkip leaves the existing file in place, and does not link the repository file
+	ConflictSkip = "skip"
+	// ConflictAdopt copies the existing file into the repository, and then replaces it with a link
+	ConflictAdopt = "adopt"
+	// ConflictFail reports an error
+	ConflictFail = "fail"
+)
+
+var (
+	// Interactive asks how to resolve each conflict when standard input is a terminal
+	Interactive = false
+
+	// onConflict is the policy which resolves conflicts when gog does not ask.
+	// By default, files are backed up unless backups are disabled.
+	onConflict string
+	// conflictChoice is the answer which applies to all remaining conflicts
+	conflictChoice string
+
+	errConflictSkipped = errors.New("skipped conflicting file")
+)
+
+// SetConflictPolicy sets the policy which resolves conflicts when gog does not ask,
+// which must be one of "backup", "overwrite", "skip", "adopt" or "fail"
+func SetConflictPolicy(s string) error {
+	switch s {
+	case "", ConflictBackup, ConflictOverwrite, ConflictSkip, ConflictAdopt, ConflictFail:
+		onConflict = s
+		return nil
+	}
+	return fmt.Errorf("invalid conflict policy %q (must be one of: backup, overwrite, skip, adopt, fail)", s)
+}
+
+// resolveConflict returns the policy which resolves the conflict between
+// intPath and the file at extPath, which was not created by gog
+func resolveConflict(repoPath, intPath, extPath string) string {
+	if conflictChoice != "" {
+		return conflictChoice
+	}
+	if Interactive && !DryRun && prompt.IsTerminal() {
+		if policy, ok := askConflict(repoPath, intPath, extPath); ok {
+			return policy
+		}
+	}
+	if onConflict != "" {
+		return onConflict
+	}
+	if backupDisabled || !config(repoPath).BackupsEnabled() {
+		return ConflictOverwrite
+	}
+	return ConflictBackup
+}
+
+// askConflict asks how to resolve a conflict until it is answered, or returns
+// false if standard input is closed. Uppercase answers apply to all remaining conflicts.
+func askConflict(repoPath, intPath, extPath string) (string, bool) {
+	question := fmt.Sprintf("%s exists. Keep [r]epository version, keep [l]ocal file, show [d]iff or [s]kip? (uppercase applies to all)", extPath)
+	for {
+		answer, ok := prompt.Ask(question)
+		if !ok {
+			return "", false
+		}
+		policy := ""
+		switch strings.ToLower(answer) {
+		case "r":
+			policy = ConflictBackup
+			if backupDisabled || !config(repoPath).BackupsEnabled() {
+				policy = ConflictOverwrite
+			}
+		case "l":
+			policy = ConflictAdopt
+		case "s":
+			policy = ConflictSkip
+		case "d":
+			text := ""
+			if d, err := Diff(repoPath, intPath); err != nil {
+				text = err.Error() + "\n"
+			} else if d != nil {
+				text = d.String()
+			}
+			prompt.Show(text)
+			continue
+		default:
+			continue
+		}
+		if answer != strings.ToLower(answer) {
+			conflictChoice = policy
+		}
+		return policy, true
+	}
+}
+
+// adoptConflict copies the file at extPath into the repository, and then
+// removes it, so that it can be replaced by a link to intPath
+func adoptConflict(intPath, extPath string) error {
+	if repository.IsTemplate(intPath) || repository.IsEncrypted(intPath) {
+		return fmt.Errorf("cannot adopt %s: %s is generated from %s", extPath, extPath, escapePathVar(intPath))
+	}
+	if err := writeCopy(extPath, intPath); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", extPath, intPath, err)
+	}
+	printAdopted(intPath, extPath)
+	if err := replace(extPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", extPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestFileResolvesConflictsWithPolicy verifies each policy's effect on an existing file and the repository file
+func TestFileResolvesConflictsWithPolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantErr     bool
+		wantLink    bool
+		wantBackup  bool
+		wantIntFile string
+		wantExtFile string
+	}{
+		{policy: ConflictBackup, wantLink: true, wantBackup: true, wantIntFile: "repository"},
+		{policy: ConflictOverwrite, wantLink: true, wantIntFile: "repository"},
+		{policy: ConflictSkip, wantIntFile: "repository", wantExtFile: "local"},
+		{policy: ConflictAdopt, wantLink: true, wantIntFile: "local"},
+		{policy: ConflictFail, wantErr: true, wantIntFile: "repository", wantExtFile: "local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			repoPath, cleanup := setupTestRepo(t)
+			defer cleanup()
+
+			testHome := t.TempDir()
+			originalHomeDir := repository.SetHomeDirForTest(testHome)
+			defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+			if err := SetConflictPolicy(tt.policy); err != nil {
+				t.Fatalf("SetConflictPolicy() failed: %v", err)
+			}
+			defer SetConflictPolicy("")
+			originalResults := results
+			defer func() { results = originalResults }()
+
+			intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+			if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+				t.Fatalf("Failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(intPath, []byte("repository"), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			extPath := filepath.Join(testHome, ".bashrc")
+			if err := os.WriteFile(extPath, []byte("local"), 0644); err != nil {
+				t.Fatalf("Failed to create existing file: %v", err)
+			}
+
+			results = Errors{}
+			if err := File(repoPath, intPath); err != nil {
+				t.Fatalf("File() failed: %v", err)
+			}
+			if _, err := Results(); (err != nil) != tt.wantErr {
+				t.Errorf("Results() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.policy == ConflictSkip && results.Skipped != 1 {
+				t.Errorf("Skipped = %d, want 1", results.Skipped)
+			}
+
+			if linkTarget, _ := os.Readlink(extPath); (linkTarget == intPath) != tt.wantLink {
+				t.Errorf("Link target = %q, want link %v", linkTarget, tt.wantLink)
+			}
+			if tt.wantExtFile != "" {
+				if content, _ := os.ReadFile(extPath); string(content) != tt.wantExtFile {
+					t.Errorf("External file = %q, want %q", content, tt.wantExtFile)
+				}
+			}
+			if content, _ := os.ReadFile(intPath); string(content) != tt.wantIntFile {
+				t.Errorf("Repository file = %q, want %q", content, tt.wantIntFile)
+			}
+			if _, err := os.Lstat(backupPath(extPath)); (err == nil) != tt.wantBackup {
+				t.Errorf("Backup exists = %v, want %v", err == nil, tt.wantBackup)
+			}
+		})
+	}
+}
+
+// TestSetConflictPolicyRejectsUnknownPolicy verifies invalid --on-conflict values are reported
+func TestSetConflictPolicyRejectsUnknownPolicy(t *testing.T) {
+	if err := SetConflictPolicy("merge"); err == nil {
+		t.Error("SetConflictPolicy() should fail for an unknown policy")
+	}
+}
@@ -0,0 +1,19 @@
+package link
+
+import (
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/secret"
+)
+
+// decryptFile writes the decrypted content of an encrypted repository file to
+// its external path, which only its owner can read
+func decryptFile(repoPath, intPath, extPath string) error {
+	content, err := secret.DecryptFile(intPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	if err := repository.ConfigureTextconv(repoPath); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	return generateFile(repoPath, intPath, extPath, content, 0600, modeEncrypted, printDecrypted)
+}
@@ -0,0 +1,74 @@
+package link
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/secret"
+)
+
+// TestFileDecryptsEncryptedFile verifies encrypted files are decrypted to a file that only its owner can read
+func TestFileDecryptsEncryptedFile(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	t.Setenv("GOG_IDENTITY_FILE", filepath.Join(t.TempDir(), "identity.txt"))
+	if _, err := secret.GenerateIdentity(); err != nil {
+		t.Fatalf("GenerateIdentity() failed: %v", err)
+	}
+	ciphertext, err := secret.Encrypt([]byte("machine example.com password secret\n"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() failed: %v", err)
+	}
+
+	intPath := filepath.Join(repoPath, "$HOME", ".netrc"+repository.EncryptedSuffix)
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, ciphertext, 0644); err != nil {
+		t.Fatalf("Failed to create encrypted file: %v", err)
+	}
+	extPath := filepath.Join(testHome, ".netrc")
+	if p := repository.ToExternalPath(repoPath, intPath); p != extPath {
+		t.Fatalf("ToExternalPath() = %q, want %q", p, extPath)
+	}
+
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	extFileInfo, err := os.Lstat(extPath)
+	if err != nil {
+		t.Fatalf("Decrypted file does not exist: %v", err)
+	}
+	if !extFileInfo.Mode().IsRegular() || extFileInfo.Mode().Perm() != 0600 {
+		t.Errorf("Decrypted file mode = %v, want a regular file with mode 0600", extFileInfo.Mode())
+	}
+	if content, _ := os.ReadFile(extPath); string(content) != "machine example.com password secret\n" {
+		t.Errorf("Decrypted content = %q", content)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateDecrypted {
+		t.Errorf("State = %q, want %q", s.State, StateDecrypted)
+	}
+
+	// git decrypts the file to show its changes
+	cmd := exec.Command("git", "config", "--get", "diff.gog-age.textconv")
+	cmd.Dir = repoPath
+	if out, err := cmd.Output(); err != nil || strings.TrimSpace(string(out)) != "gog textconv" {
+		t.Errorf("textconv = %q, %v, want %q", out, err, "gog textconv")
+	}
+
+	if err := os.WriteFile(extPath, []byte("changed"), 0600); err != nil {
+		t.Fatalf("Failed to modify decrypted file: %v", err)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateDrifted {
+		t.Errorf("State after modification = %q, want %q", s.State, StateDrifted)
+	}
+}
@@ -0,0 +1,102 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andornaut/gog/internal/diff"
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/secret"
+)
+
+// devNull is the name of a missing file in diffs
+const devNull = "/dev/null"
+
+// FileDiff describes how a repository file differs from the file at its external path
+type FileDiff struct {
+	IntPath string
+	ExtPath string
+	// Diff is a unified diff, or empty if the files cannot be compared
+	Diff string
+	// Reason explains why the files cannot be compared
+	Reason string
+}
+
+// String returns the unified diff, or why the files cannot be compared
+func (d FileDiff) String() string {
+	if d.Reason != "" {
+		return fmt.Sprintf("Differs: %s (%s)\n", d.ExtPath, d.Reason)
+	}
+	return d.Diff
+}
+
+// Diff returns how a repository file differs from the file at its external
+// path, or nil if they are identical. Templates and encrypted files are
+// compared by the content that gog would write.
+func Diff(repoPath, intPath string) (*FileDiff, error) {
+	extPath := repository.ToExternalPath(repoPath, intPath)
+	intContent, err := linkedContent(intPath)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &FileDiff{IntPath: intPath, ExtPath: extPath}
+	extFileInfo, err := os.Stat(extPath)
+	switch {
+	case os.IsNotExist(err):
+		// Nothing, or a broken symlink, exists at the external path
+		d.Diff = diff.Unified(intPath, devNull, intContent, nil)
+		return d, nil
+	case err != nil:
+		return nil, err
+	case extFileInfo.IsDir():
+		d.Reason = "the external path is a directory"
+		return d, nil
+	}
+	extContent, err := os.ReadFile(extPath)
+	if err != nil {
+		return nil, err
+	}
+	if d.Diff = diff.Unified(intPath, extPath, intContent, extContent); d.Diff == "" {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// DiffDir returns how each file in a repository directory that would be
+// linked differs from the file at its external path, omitting identical files
+func DiffDir(repoPath, intPath string) ([]FileDiff, error) {
+	var diffs []FileDiff
+	err := walk(repoPath, intPath, func(p string, info os.FileInfo) error {
+		if info.IsDir() {
+			if isIgnored(repoPath, p, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isIgnored(repoPath, p, false) || !isSelectedVariant(p) {
+			return nil
+		}
+		d, err := Diff(repoPath, p)
+		if err != nil {
+			return fmt.Errorf("failed to compare %s: %w", p, err)
+		}
+		if d != nil {
+			diffs = append(diffs, *d)
+		}
+		return nil
+	})
+	return diffs, err
+}
+
+// linkedContent returns the content that gog writes or links to the external path of a repository file
+func linkedContent(intPath string) ([]byte, error) {
+	switch {
+	case repository.IsTemplate(intPath):
+		return Render(intPath)
+	case repository.IsEncrypted(intPath):
+		return secret.DecryptFile(intPath)
+	}
+	return os.ReadFile(intPath)
+}
@@ -0,0 +1,74 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestDiffDirComparesRepositoryWithFilesystem verifies changed, missing and binary files are reported
+func TestDiffDirComparesRepositoryWithFilesystem(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	files := map[string][2]string{
+		// Repository and external content
+		".bashrc":          {"alias ls='ls -F'\n", "alias ls='ls -F'\n"},
+		".config/app/conf": {"a\nb\nc\n", "a\nB\nc\n"},
+		".profile":         {"export EDITOR=vim\n", ""},
+		".local/bin/tool":  {"\x00\x01", "\x00\x02"},
+	}
+	for name, content := range files {
+		intPath := filepath.Join(repoPath, "$HOME", name)
+		if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(intPath, []byte(content[0]), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		if content[1] == "" {
+			continue
+		}
+		extPath := filepath.Join(testHome, name)
+		if err := os.MkdirAll(filepath.Dir(extPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(extPath, []byte(content[1]), 0644); err != nil {
+			t.Fatalf("Failed to create external file: %v", err)
+		}
+	}
+
+	diffs, err := DiffDir(repoPath, repoPath)
+	if err != nil {
+		t.Fatalf("DiffDir() failed: %v", err)
+	}
+	if len(diffs) != 3 {
+		t.Errorf("DiffDir() found %d changed files, want 3", len(diffs))
+	}
+	var d string
+	for _, fd := range diffs {
+		if fd.ExtPath != repository.ToExternalPath(repoPath, fd.IntPath) {
+			t.Errorf("DiffDir() external path = %q, want the external path of %q", fd.ExtPath, fd.IntPath)
+		}
+		d += fd.Diff
+	}
+	for _, expected := range []string{
+		"+++ " + filepath.Join(testHome, ".config/app/conf") + "\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		"+++ /dev/null\n@@ -1,1 +0,0 @@\n-export EDITOR=vim\n",
+		"Binary files " + filepath.Join(repoPath, "$HOME", ".local/bin/tool") + " and " + filepath.Join(testHome, ".local/bin/tool") + " differ\n",
+	} {
+		if !strings.Contains(d, expected) {
+			t.Errorf("DiffDir() = %q, want it to contain %q", d, expected)
+		}
+	}
+	if strings.Contains(d, ".bashrc") {
+		t.Errorf("DiffDir() = %q, want identical files to be omitted", d)
+	}
+}
@@ -0,0 +1,86 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// FailFast stops linking at the first file that cannot be linked, instead
+	// of reporting the error and continuing with the next file
+	FailFast = false
+
+	results Errors
+)
+
+// Summary counts the outcomes of linking files
+type Summary struct {
+	Linked  int
+	Skipped int
+	Failed  int
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d linked, %d skipped, %d failed", s.Linked, s.Skipped, s.Failed)
+}
+
+// FileError is an error that prevented a file from being linked
+type FileError struct {
+	IntPath string
+	ExtPath string
+	Err     error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.IntPath, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Errors aggregates the errors of all files that could not be linked
+type Errors struct {
+	Summary
+	Errs []*FileError
+}
+
+func (e *Errors) Error() string {
+	return e.Summary.String()
+}
+
+func (e *Errors) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Results returns a summary of the files that were linked since the program
+// started, and an *Errors if any of them failed
+func Results() (Summary, error) {
+	if results.Failed == 0 {
+		return results.Summary, nil
+	}
+	errs := results
+	return results.Summary, &errs
+}
+
+// fail reports that a file could not be linked. It returns the error if
+// FailFast is true, which stops the walk, or nil to continue with the next file.
+// A file that was skipped to resolve a conflict is counted as skipped instead.
+func fail(intPath, extPath string, err error) error {
+	if errors.Is(err, errConflictSkipped) {
+		results.Skipped++
+		return nil
+	}
+	printError(intPath, extPath, err)
+	fileErr := &FileError{IntPath: intPath, ExtPath: extPath, Err: err}
+	results.Failed++
+	results.Errs = append(results.Errs, fileErr)
+	if FailFast {
+		return fileErr
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package link
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestDirAggregatesFileErrors verifies failures are collected and summarized
+func TestDirAggregatesFileErrors(t *testing.T) {
+	originalResults := results
+	results = Errors{}
+	defer func() { results = originalResults }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	// Set up home directory for testing
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	// Create test files in the repo, one of which conflicts with an external directory
+	for _, name := range []string{".bashrc", ".config", ".profile"} {
+		intPath := filepath.Join(repoPath, "$HOME", name)
+		if err = os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err = os.WriteFile(intPath, []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if err = os.MkdirAll(filepath.Join(testHome, ".config"), 0755); err != nil {
+		t.Fatalf("Failed to create conflicting directory: %v", err)
+	}
+
+	if err = Dir(repoPath, repoPath); err != nil {
+		t.Fatalf("Dir() should continue after a file fails, got: %v", err)
+	}
+
+	summary, err := Results()
+	var errs *Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Results() error = %v, want *Errors", err)
+	}
+	if summary.Linked != 2 || summary.Failed != 1 {
+		t.Errorf("Results() = %s, want 2 linked and 1 failed", summary)
+	}
+	if len(errs.Errs) != 1 || errs.Errs[0].ExtPath != filepath.Join(testHome, ".config") {
+		t.Errorf("Results() errors = %v, want the conflicting directory", errs.Errs)
+	}
+}
+
+// TestFileFailFastReturnsError verifies the first failure stops the walk
+func TestFileFailFastReturnsError(t *testing.T) {
+	originalResults := results
+	results = Errors{}
+	defer func() { results = originalResults }()
+
+	originalFailFast := FailFast
+	FailFast = true
+	defer func() { FailFast = originalFailFast }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	// Set up home directory for testing
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	intPath := filepath.Join(repoPath, "$HOME", ".config")
+	if err = os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err = os.WriteFile(intPath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err = os.MkdirAll(filepath.Join(testHome, ".config"), 0755); err != nil {
+		t.Fatalf("Failed to create conflicting directory: %v", err)
+	}
+
+	err = File(repoPath, intPath)
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) {
+		t.Fatalf("File() error = %v, want *FileError", err)
+	}
+}
@@ -0,0 +1,275 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andornaut/gog/internal/copy"
+	"github.com/andornaut/gog/internal/git"
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// unfolded are the external paths of directories which were unfolded, and
+// must not be folded again
+var unfolded = make(map[string]bool)
+
+// isFolded returns true if a repository directory is configured to be linked
+// as a single symbolic link
+func isFolded(repoPath, intPath string) bool {
+	return intPath != repoPath && config(repoPath).IsFolded(strings.TrimPrefix(intPath, repoPath+"/"))
+}
+
+// foldDir links a repository directory as a single symbolic link. It returns
+// false if the directory cannot be folded, in which case its files should be
+// linked individually.
+func foldDir(repoPath, intPath, extPath string) (bool, error) {
+	if unfolded[extPath] {
+		return false, nil
+	}
+	if other := sharedBy(repoPath, intPath); other != "" {
+		printNotFolded(intPath, extPath, fmt.Sprintf("repository %s also contains the directory", other))
+		return false, nil
+	}
+	if reason := unfoldableReason(repoPath, intPath); reason != "" {
+		printNotFolded(intPath, extPath, reason)
+		return false, nil
+	}
+
+	extFileInfo, err := os.Lstat(extPath)
+	linkTarget, _ := readlink(extPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return true, err
+	case linkTarget == intPath:
+		// Already folded
+		return true, foldedDir(repoPath, intPath, extPath)
+	case extFileInfo.IsDir():
+		// The directory can be replaced if it only contains links and copies of the repository's files
+		if !isRecreatable(intPath, extPath) {
+			printNotFolded(intPath, extPath, "the directory contains other files")
+			return false, nil
+		}
+		if err := removeTree(repoPath, extPath); err != nil {
+			return true, err
+		}
+		printReplaced(intPath, extPath, "")
+	default:
+		if err := clearExtPath(repoPath, intPath, extPath); err != nil {
+			return true, err
+		}
+	}
+
+	if err := mkdirAll(filepath.Dir(extPath)); err != nil {
+		return true, fmt.Errorf("failed to create directory %s: %w", filepath.Dir(extPath), err)
+	}
+	if err := symlink(intPath, extPath); err != nil {
+		return true, fmt.Errorf("failed to create symlink from %s to %s: %w", extPath, intPath, err)
+	}
+	printLinked(intPath, extPath)
+	return true, foldedDir(repoPath, intPath, extPath)
+}
+
+// foldedDir records a folded directory, and then stages its files, except for
+// those that git ignores
+func foldedDir(repoPath, intPath, extPath string) error {
+	if err := recordSymlink(repoPath, intPath, extPath); err != nil {
+		return err
+	}
+	if DryRun {
+		printStaged(intPath)
+	} else if err := git.Run(repoPath, "add", intPath); err != nil {
+		return fmt.Errorf("failed to add %s to git: %w", intPath, err)
+	}
+	results.Linked++
+	return nil
+}
+
+// sharedBy returns the name of another repository which contains the same
+// directory as intPath, or an empty string if there is none
+func sharedBy(repoPath, intPath string) string {
+	names, err := repository.List()
+	if err != nil {
+		return ""
+	}
+	relPath := strings.TrimPrefix(intPath, repoPath+"/")
+	for _, name := range names {
+		p := filepath.Join(repository.BaseDir, name)
+		if p == repoPath {
+			continue
+		}
+		if fileInfo, err := os.Stat(filepath.Join(p, relPath)); err == nil && fileInfo.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// unfoldableReason returns why the files below a repository directory cannot
+// be linked through a single symbolic link, which exposes each of them
+// unchanged, or an empty string if they can
+func unfoldableReason(repoPath, intPath string) string {
+	m, err := repository.LoadMetadata(repoPath)
+	if err != nil {
+		return err.Error()
+	}
+	reason := ""
+	err = filepath.Walk(intPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		_, hasMetadata := m[strings.TrimPrefix(p, repoPath+"/")]
+		switch {
+		case isIgnored(repoPath, p, info.IsDir()):
+			reason = "it contains ignored files"
+		case info.IsDir():
+			return nil
+		case repository.IsTemplate(p):
+			reason = "it contains templates"
+		case repository.IsEncrypted(p):
+			reason = "it contains encrypted files"
+		case strings.Contains(info.Name(), repository.VariantSeparator):
+			reason = "it contains variants"
+		case mode(repoPath, p) != repository.ModeSymlink:
+			reason = "it contains files which are not symlinked"
+		case hasMetadata:
+			reason = "it contains files with recorded permissions"
+		default:
+			return nil
+		}
+		return filepath.SkipAll
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return reason
+}
+
+// isRecreatable returns true if the directory at extPath only contains links
+// to the files below intPath, and identical copies of them
+func isRecreatable(intPath, extPath string) bool {
+	recreatable := true
+	err := filepath.Walk(extPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		p2 := filepath.Join(intPath, strings.TrimPrefix(p, extPath))
+		if linkTarget, _ := readlink(p); linkTarget != p2 && (!info.Mode().IsRegular() || !isSameContent(p, p2)) {
+			recreatable = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return err == nil && recreatable
+}
+
+// removeTree removes the links, copies and directories at and below extPath,
+// which were checked by isRecreatable, and forgets them
+func removeTree(repoPath, extPath string) error {
+	var paths []string
+	err := filepath.Walk(extPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range slices.Backward(paths) {
+		if err := replace(p); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+		if err := forget(repoPath, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unfold replaces the folded directory link at extPath with a directory, in
+// which each of the linked repository directory's files is linked individually,
+// so that other repositories can link files into it
+func unfold(extPath string) error {
+	intPath, err := readlink(extPath)
+	if err != nil {
+		return err
+	}
+	repoPath := filepath.Join(repository.BaseDir, repositoryName(intPath))
+	if err := removeLink(extPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", extPath, err)
+	}
+	if err := forget(repoPath, extPath); err != nil {
+		return err
+	}
+	printUnfolded(intPath, extPath)
+	unfolded[extPath] = true
+	if DryRun {
+		// The files still appear to exist through the link
+		return walk(repoPath, intPath, func(p string, info os.FileInfo) error {
+			if !info.IsDir() && !isIgnored(repoPath, p, false) && isSelectedVariant(p) {
+				printLinked(p, repository.ToExternalPath(repoPath, p))
+			}
+			return nil
+		})
+	}
+	if err := mkdirAll(extPath); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", extPath, err)
+	}
+	return Dir(repoPath, intPath)
+}
+
+// foldedParent returns the nearest parent directory of extPath which is a
+// folded directory link, or an empty string if there is none
+func foldedParent(extPath string) string {
+	for dir := filepath.Dir(extPath); dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if !isSymlink(dir) || unfolded[dir] {
+			continue
+		}
+		linkTarget, err := readlink(dir)
+		if err != nil || !isRecordedLink(dir, linkTarget) {
+			return ""
+		}
+		if fileInfo, err := os.Stat(linkTarget); err == nil && fileInfo.IsDir() {
+			return dir
+		}
+		return ""
+	}
+	return ""
+}
+
+// unlinkFolded replaces a folded directory link with a copy of the repository directory
+func unlinkFolded(repoPath, intPath, extPath string) error {
+	if err := removeLink(extPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", extPath, err)
+	}
+	if !DryRun {
+		if err := copy.Dir(intPath, extPath, func(string, string) bool { return false }); err != nil {
+			return err
+		}
+	}
+	if err := forget(repoPath, extPath); err != nil {
+		return err
+	}
+	printUnLinked(intPath)
+	if DryRun {
+		printUnstaged(intPath)
+		return nil
+	}
+	return git.Run(repoPath, "rm", "-rqf", intPath)
+}
+
+// isSameContent returns true if the files at a and b have the same content
+func isSameContent(a, b string) bool {
+	aHash, err := state.Hash(a)
+	if err != nil {
+		return false
+	}
+	bHash, err := state.Hash(b)
+	return err == nil && aHash == bHash
+}
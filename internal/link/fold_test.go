@@ -0,0 +1,132 @@
+package link
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestDirFoldsAndUnfoldsDirectory verifies a marked directory is linked as a
+// single symlink, and unfolded when another repository contributes files to it
+func TestDirFoldsAndUnfoldsDirectory(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	if err := os.WriteFile(filepath.Join(repoPath, repository.ConfigFileName), []byte(`fold = ["$HOME/.config/nvim"]`), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	intDir := filepath.Join(repoPath, "$HOME", ".config", "nvim")
+	for _, name := range []string{"init.lua", "lua/plugins.lua"} {
+		p := filepath.Join(intDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	extDir := filepath.Join(testHome, ".config", "nvim")
+
+	if err := Dir(repoPath, repoPath); err != nil {
+		t.Fatalf("Dir() failed: %v", err)
+	}
+	if linkTarget, err := os.Readlink(extDir); err != nil || linkTarget != intDir {
+		t.Fatalf("Directory link target = %q, %v, want %q", linkTarget, err, intDir)
+	}
+	if s := FileState(repoPath, filepath.Join(intDir, "init.lua")); s.State != StateLinked {
+		t.Errorf("State of a file in the folded directory = %q, want %q", s.State, StateLinked)
+	}
+
+	// Another repository contributes a file to the directory
+	otherRepoPath := filepath.Join(repository.BaseDir, "work")
+	otherIntPath := filepath.Join(otherRepoPath, "$HOME", ".config", "nvim", "work.lua")
+	if err := os.MkdirAll(filepath.Dir(otherIntPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if out, err := exec.Command("git", "init", "-q", otherRepoPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+	if err := os.WriteFile(otherIntPath, []byte("work"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer func() { unfolded = make(map[string]bool) }()
+
+	if err := Dir(otherRepoPath, otherRepoPath); err != nil {
+		t.Fatalf("Dir() failed: %v", err)
+	}
+	if isSymlink(extDir) {
+		t.Fatal("Directory should be unfolded")
+	}
+	for name, intPath := range map[string]string{
+		"init.lua":        filepath.Join(intDir, "init.lua"),
+		"lua/plugins.lua": filepath.Join(intDir, "lua/plugins.lua"),
+		"work.lua":        otherIntPath,
+	} {
+		if linkTarget, err := os.Readlink(filepath.Join(extDir, name)); err != nil || linkTarget != intPath {
+			t.Errorf("Link target of %s = %q, %v, want %q", name, linkTarget, err, intPath)
+		}
+	}
+}
+
+// TestDirDoesNotFoldDirectoriesWhoseFilesAreNotSymlinked verifies a directory
+// is only folded if each of its files would be symlinked unchanged
+func TestDirDoesNotFoldDirectoriesWhoseFilesAreNotSymlinked(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		files    map[string]string
+	}{
+		{"template", "init.lua" + repository.TemplateSuffix, nil},
+		{"encrypted file", "init.lua" + repository.EncryptedSuffix, nil},
+		{"variant", "init.lua" + repository.VariantSeparator + "os.plan9", nil},
+		{"ignored file", "lazy-lock.json", map[string]string{repository.IgnoreFileName: "lazy-lock.json\n"}},
+		{"copy mode", "init.lua", map[string]string{repository.ConfigFileName: "[modes]\n\"$HOME/.config/nvim/lua/init.lua\" = \"copy\"\n"}},
+		{"metadata", "init.lua", map[string]string{repository.MetadataFileName: "[\"$HOME/.config/nvim/lua/init.lua\"]\nmode = \"0600\"\n"}},
+	}
+	t.Setenv("GOG_IDENTITY_FILE", filepath.Join(t.TempDir(), "identity.txt"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoPath, cleanup := setupTestRepo(t)
+			defer cleanup()
+			testHome := t.TempDir()
+			defer repository.SetHomeDirForTest(repository.SetHomeDirForTest(testHome))
+
+			files := map[string]string{
+				repository.ConfigFileName:               "fold = [\"$HOME/.config/nvim\"]\n",
+				"$HOME/.config/nvim/other.lua":          "other",
+				"$HOME/.config/nvim/lua/" + tt.fileName: "content",
+			}
+			for name, content := range tt.files {
+				files[name] += content
+			}
+			for name, content := range files {
+				p := filepath.Join(repoPath, name)
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatalf("Failed to create dir: %v", err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to create test file: %v", err)
+				}
+			}
+
+			if err := Dir(repoPath, repoPath); err != nil {
+				t.Fatalf("Dir() failed: %v", err)
+			}
+			extDir := filepath.Join(testHome, ".config", "nvim")
+			if isSymlink(extDir) {
+				t.Fatal("Directory should not be folded")
+			}
+			intPath := filepath.Join(repoPath, "$HOME", ".config", "nvim", "other.lua")
+			if linkTarget, err := os.Readlink(filepath.Join(extDir, "other.lua")); err != nil || linkTarget != intPath {
+				t.Errorf("Link target of other.lua = %q, %v, want %q", linkTarget, err, intPath)
+			}
+		})
+	}
+}
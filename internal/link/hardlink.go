@@ -0,0 +1,69 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// hardlinkFile creates a hard link from a repository file to the root
+// filesystem, or copies it if the external path is on a different device
+func hardlinkFile(repoPath, intPath, extPath string) error {
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	if e := s.Get(extPath); e != nil && e.Mode == repository.ModeCopy {
+		// Hard linking failed previously, because the file is on a different device
+		return copyFile(repoPath, intPath, extPath)
+	}
+
+	extFileInfo, err := os.Lstat(extPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fail(intPath, extPath, err)
+	case extFileInfo.IsDir():
+		return fail(intPath, extPath, fmt.Errorf("cannot create hard link: %s exists and is a directory (remove the directory or use a different location)", extPath))
+	case extFileInfo.Mode().IsRegular() && isSameFile(extPath, intPath):
+		// Already linked
+		if err := recordHardlink(s, intPath, extPath); err != nil {
+			return fail(intPath, extPath, err)
+		}
+		return addToGit(repoPath, intPath)
+	case isReplaced(repoPath, intPath, extPath):
+		printReplacedByFile(intPath, extPath)
+		results.Skipped++
+		return nil
+	default:
+		// The file may also be a hard link that was broken when git replaced the repository file
+		if err := clearExtPath(repoPath, intPath, extPath); err != nil {
+			return fail(intPath, extPath, err)
+		}
+	}
+
+	if err := hardlink(intPath, extPath); err != nil {
+		if errors.Is(err, syscall.EXDEV) {
+			printHardlinkFallback(intPath, extPath)
+			return copyFile(repoPath, intPath, extPath)
+		}
+		return fail(intPath, extPath, fmt.Errorf("failed to create hard link from %s to %s: %w", extPath, intPath, err))
+	}
+	printHardlinked(intPath, extPath)
+	if err := recordHardlink(s, intPath, extPath); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	return addToGit(repoPath, intPath)
+}
+
+func recordHardlink(s *state.State, intPath, extPath string) error {
+	hash, err := state.Hash(intPath)
+	if err != nil {
+		return err
+	}
+	return record(s, repository.ModeHardlink, intPath, extPath, hash)
+}
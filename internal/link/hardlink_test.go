@@ -0,0 +1,77 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestHardlinkMode verifies hard links are created, recreated after git replaces
+// the repository file, and replaced by copies when unlinked
+func TestHardlinkMode(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	// The home directory must be on the same device as the repository
+	testHome := filepath.Join(filepath.Dir(repoPath), "home")
+	if err := os.MkdirAll(testHome, 0755); err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	if err := os.WriteFile(filepath.Join(repoPath, repository.ConfigFileName), []byte(`mode = "hardlink"`), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	extPath := filepath.Join(testHome, ".bashrc")
+
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if isSymlink(extPath) || !isSameFile(extPath, intPath) {
+		t.Fatal("External path should be a hard link to the repository file")
+	}
+	if s := FileState(repoPath, intPath); s.State != StateLinked {
+		t.Errorf("State = %q, want %q", s.State, StateLinked)
+	}
+
+	// Replace the repository file with a new inode, like `git checkout` does
+	tmpPath := intPath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte("v2"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Rename(tmpPath, intPath); err != nil {
+		t.Fatalf("Failed to replace test file: %v", err)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateOutdated {
+		t.Errorf("State = %q, want %q", s.State, StateOutdated)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if !isSameFile(extPath, intPath) {
+		t.Error("External path should be hard linked again")
+	}
+	if _, err := os.Lstat(backupPath(extPath)); !os.IsNotExist(err) {
+		t.Error("A broken hard link should be replaced without a backup")
+	}
+
+	if err := UnlinkFile(repoPath, intPath); err != nil {
+		t.Fatalf("UnlinkFile() failed: %v", err)
+	}
+	if isSameFile(extPath, intPath) {
+		t.Error("External path should be a copy after unlinking")
+	}
+	if content, _ := os.ReadFile(extPath); string(content) != "v2" {
+		t.Errorf("Copy content = %q, want %q", content, "v2")
+	}
+}
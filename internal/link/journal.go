@@ -0,0 +1,441 @@
+package link
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/andornaut/gog/internal/copy"
+	"github.com/andornaut/gog/internal/privileged"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// Journaled actions
+const (
+	// journalCreated is a file or symbolic link that was created at Path
+	journalCreated = "created"
+	// journalDirectory is a directory that was created at Path
+	journalDirectory = "directory"
+	// journalRemoved is a file or symbolic link that was removed from Path
+	journalRemoved = "removed"
+	// journalRemovedDirectory is an empty directory that was removed from Path
+	journalRemovedDirectory = "removed-directory"
+	// journalRenamed is a file that was renamed from Source to Path
+	journalRenamed = "renamed"
+)
+
+// ErrInterrupted is returned when linking stops because of `Interrupt`
+var ErrInterrupted = errors.New("interrupted")
+
+var (
+	interrupted atomic.Bool
+	journal     *journalFile
+)
+
+type journalEntry struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+	// LinkTarget is the target of a symbolic link that was created or removed
+	LinkTarget string `json:"link_target,omitempty"`
+	// Hash is the SHA-256 digest of a file that was created
+	Hash string `json:"hash,omitempty"`
+	// SavedPath is a copy of a file that was removed or overwritten
+	SavedPath string `json:"saved_path,omitempty"`
+}
+
+type journalFile struct {
+	f       *os.File
+	entries []journalEntry
+}
+
+// Interrupt stops linking before the next file, e.g. when the user presses Ctrl-C.
+// It is safe to call from another goroutine.
+func Interrupt() {
+	interrupted.Store(true)
+}
+
+// BeginJournal starts recording the changes that are made to the filesystem,
+// so that they can be rolled back. It replaces the journal of the previous apply.
+func BeginJournal() error {
+	if DryRun {
+		return nil
+	}
+	if err := state.SaveAll(); err != nil {
+		return err
+	}
+	dir := journalDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "saved"), 0700); err != nil {
+		return err
+	}
+	if err := copyStateFiles(state.Dir(), filepath.Join(dir, "before")); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "journal.ndjson"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	journal = &journalFile{f: f}
+	return nil
+}
+
+// CloseJournal stops recording changes. The journal is kept, so that `Undo`
+// can roll back the changes later.
+func CloseJournal() error {
+	if journal == nil {
+		return nil
+	}
+	err := journal.f.Close()
+	if err == nil {
+		err = state.SaveAll()
+	}
+	if err == nil {
+		err = copyStateFiles(state.Dir(), filepath.Join(journalDir(), "after"))
+	}
+	return err
+}
+
+// Rollback undoes the changes that were recorded since `BeginJournal`, in
+// reverse order, and restores gog's state
+func Rollback() error {
+	if journal == nil {
+		return nil
+	}
+	entries := journal.entries
+	journal = nil
+	return rollback(entries)
+}
+
+// Undo undoes the changes of the last apply, unless gog's state changed since then
+func Undo() error {
+	dir := journalDir()
+	b, err := os.ReadFile(filepath.Join(dir, "journal.ndjson"))
+	if os.IsNotExist(err) {
+		return errors.New("nothing to undo")
+	}
+	if err != nil {
+		return err
+	}
+	var entries []journalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("invalid journal %s: %w", dir, err)
+		}
+		entries = append(entries, e)
+	}
+	if changed, err := stateChanged(filepath.Join(dir, "after")); err != nil {
+		return err
+	} else if changed {
+		return errors.New("cannot undo the last apply, because files were linked or unlinked since then")
+	}
+	return rollback(entries)
+}
+
+func rollback(entries []journalEntry) error {
+	// During a dry run, the filesystem is not changed, so the changes that would
+	// have been undone are tracked here instead
+	exists := make(map[string]bool)
+	for i, e := range slices.Backward(entries) {
+		if err := undoEntry(e, exists); err != nil {
+			if !DryRun {
+				// Keep the changes that were not undone, so that they can be undone later
+				if err := writeJournal(entries[:i+1]); err != nil {
+					return err
+				}
+			}
+			return fmt.Errorf("failed to undo %d of %d changes (fix the error, and then run `gog undo`): %w", i+1, len(entries), err)
+		}
+	}
+	if DryRun {
+		return nil
+	}
+	if err := restoreStateFiles(filepath.Join(journalDir(), "before")); err != nil {
+		return err
+	}
+	return os.RemoveAll(journalDir())
+}
+
+// writeJournal replaces the journal's entries
+func writeJournal(entries []journalEntry) error {
+	var b bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		b.Write(append(line, '\n'))
+	}
+	return os.WriteFile(filepath.Join(journalDir(), "journal.ndjson"), b.Bytes(), 0600)
+}
+
+// undoEntry undoes a journaled change, unless it was undone already. The
+// existence of paths that were changed during a dry run is tracked in exists.
+func undoEntry(e journalEntry, exists map[string]bool) error {
+	pathExists := func(p string) bool {
+		if v, ok := exists[p]; ok {
+			return v
+		}
+		_, err := os.Lstat(p)
+		return err == nil
+	}
+	if DryRun {
+		defer func() {
+			switch e.Action {
+			case journalRenamed:
+				exists[e.Path] = false
+				exists[e.Source] = true
+			case journalRemoved, journalRemovedDirectory:
+				exists[e.Path] = true
+			default:
+				exists[e.Path] = false
+			}
+		}()
+	}
+
+	switch e.Action {
+	case journalCreated:
+		if !pathExists(e.Path) {
+			return nil
+		}
+		if !isJournaledFile(e) {
+			return fmt.Errorf("cannot remove %s: it changed since it was created", e.Path)
+		}
+		printUndone(e.Path, "remove")
+		return removeLink(e.Path)
+	case journalDirectory:
+		if !pathExists(e.Path) {
+			return nil
+		}
+		printUndone(e.Path, "remove directory")
+		if DryRun {
+			return nil
+		}
+		return privileged.Remove(e.Path)
+	case journalRenamed:
+		if !pathExists(e.Path) {
+			return nil
+		}
+		if pathExists(e.Source) {
+			return fmt.Errorf("cannot rename %s to %s: %s exists", e.Path, e.Source, e.Source)
+		}
+		printUndone(e.Path, "rename to "+e.Source)
+		if DryRun {
+			return nil
+		}
+		return privileged.Rename(e.Path, e.Source)
+	case journalRemoved:
+		if pathExists(e.Path) {
+			return fmt.Errorf("cannot restore %s: it exists", e.Path)
+		}
+		printUndone(e.Path, "restore")
+		if DryRun {
+			return nil
+		}
+		if e.LinkTarget != "" {
+			return privileged.Symlink(e.LinkTarget, e.Path)
+		}
+		return privileged.CopyFile(e.SavedPath, e.Path)
+	case journalRemovedDirectory:
+		if pathExists(e.Path) {
+			return nil
+		}
+		printUndone(e.Path, "create directory")
+		if DryRun {
+			return nil
+		}
+		return privileged.Mkdir(e.Path, 0755)
+	}
+	return fmt.Errorf("invalid journal action %q", e.Action)
+}
+
+// isJournaledFile returns true if the file or link at e.Path is the one that was created
+func isJournaledFile(e journalEntry) bool {
+	if e.LinkTarget != "" {
+		linkTarget, err := os.Readlink(e.Path)
+		return err == nil && linkTarget == e.LinkTarget
+	}
+	hash, err := state.Hash(e.Path)
+	return err == nil && !isSymlink(e.Path) && hash == e.Hash
+}
+
+// journalCreate records that a file or symbolic link was created at p
+func journalCreate(p string) error {
+	if journal == nil {
+		return nil
+	}
+	e := journalEntry{Action: journalCreated, Path: p}
+	if isSymlink(p) {
+		e.LinkTarget, _ = os.Readlink(p)
+	} else {
+		hash, err := state.Hash(p)
+		if err != nil {
+			return err
+		}
+		e.Hash = hash
+	}
+	return journal.add(e)
+}
+
+// journalMkdir records that the directories in dirs were created, from the outermost one
+func journalMkdir(dirs []string) error {
+	if journal == nil {
+		return nil
+	}
+	for _, dir := range slices.Backward(dirs) {
+		if err := journal.add(journalEntry{Action: journalDirectory, Path: dir}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// journalRename records that oldPath was renamed to newPath
+func journalRename(oldPath, newPath string) error {
+	if journal == nil {
+		return nil
+	}
+	return journal.add(journalEntry{Action: journalRenamed, Path: newPath, Source: oldPath})
+}
+
+// journalRemoval saves the file, symbolic link or empty directory at p before it is removed or
+// overwritten. The returned entry is recorded by `addRemoval` once that
+// succeeds. It is nil if there is no journal or nothing at p.
+func journalRemoval(p string) (*journalEntry, error) {
+	if journal == nil {
+		return nil, nil
+	}
+	fileInfo, err := os.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &journalEntry{Action: journalRemoved, Path: p}
+	switch {
+	case fileInfo.Mode()&os.ModeSymlink != 0:
+		if e.LinkTarget, err = os.Readlink(p); err != nil {
+			return nil, err
+		}
+	case fileInfo.Mode().IsRegular():
+		e.SavedPath = filepath.Join(journalDir(), "saved", strconv.Itoa(len(journal.entries)))
+		if err := privileged.CopyFile(p, e.SavedPath); err != nil {
+			return nil, fmt.Errorf("failed to save %s: %w", p, err)
+		}
+	case fileInfo.IsDir():
+		e.Action = journalRemovedDirectory
+	default:
+		return nil, fmt.Errorf("cannot remove %s: it is not a file, symbolic link or directory", p)
+	}
+	return e, nil
+}
+
+// add appends an entry to the journal, which is written immediately, so that
+// the changes can be undone even if gog exits unexpectedly
+func (j *journalFile) add(e journalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.entries = append(j.entries, e)
+	return nil
+}
+
+// addRemoval appends an entry which was returned by `journalRemoval`
+func addRemoval(e *journalEntry) error {
+	if journal == nil || e == nil {
+		return nil
+	}
+	return journal.add(*e)
+}
+
+// missingDirs returns p and those of its parent directories that do not exist,
+// from the innermost one
+func missingDirs(p string) []string {
+	var dirs []string
+	for ; p != filepath.Dir(p); p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			break
+		}
+		dirs = append(dirs, p)
+	}
+	return dirs
+}
+
+func journalDir() string {
+	// State files end with ".json", so this cannot conflict with one
+	return filepath.Join(state.Dir(), "journal.d")
+}
+
+// copyStateFiles copies the state files from src to dst
+func copyStateFiles(src, dst string) error {
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(src, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := copy.File(p, filepath.Join(dst, filepath.Base(p))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreStateFiles replaces the state files with those that were copied to dir
+func restoreStateFiles(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(state.Dir(), "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	state.Discard()
+	return copyStateFiles(dir, state.Dir())
+}
+
+// stateChanged returns true if the state files differ from those that were copied to dir
+func stateChanged(dir string) (bool, error) {
+	current, err := filepath.Glob(filepath.Join(state.Dir(), "*.json"))
+	if err != nil {
+		return false, err
+	}
+	saved, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return false, err
+	}
+	if len(current) != len(saved) {
+		return true, nil
+	}
+	for _, p := range current {
+		a, err := os.ReadFile(p)
+		if err != nil {
+			return false, err
+		}
+		b, err := os.ReadFile(filepath.Join(dir, filepath.Base(p)))
+		if err != nil || !bytes.Equal(a, b) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,122 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// setupJournalTest creates a repository with a nested file and a file that replaces an existing one
+func setupJournalTest(t *testing.T) (repoPath, testHome string) {
+	repoPath, cleanup := setupTestRepo(t)
+	t.Cleanup(cleanup)
+
+	originalBackupDisabled := backupDisabled
+	backupDisabled = false
+	t.Cleanup(func() { backupDisabled = originalBackupDisabled })
+
+	testHome = t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	t.Cleanup(func() { repository.SetHomeDirForTest(originalHomeDir) })
+
+	for _, p := range []string{".bashrc", ".config/app/config"} {
+		intPath := filepath.Join(repoPath, "$HOME", p)
+		if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(intPath, []byte("repository"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(testHome, ".bashrc"), []byte("existing"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+	return repoPath, testHome
+}
+
+// assertUndone verifies the filesystem and state are as they were before the repository was linked
+func assertUndone(t *testing.T, repoPath, testHome string) {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(testHome, ".bashrc"))
+	if err != nil || string(content) != "existing" {
+		t.Errorf(".bashrc = %q, %v, want the existing file", content, err)
+	}
+	if _, err := os.Lstat(backupPath(filepath.Join(testHome, ".bashrc"))); !os.IsNotExist(err) {
+		t.Error("Backup should be renamed back to .bashrc")
+	}
+	if _, err := os.Lstat(filepath.Join(testHome, ".config")); !os.IsNotExist(err) {
+		t.Error("Created directories should be removed")
+	}
+	s, err := state.Load(repoPath)
+	if err != nil {
+		t.Fatalf("state.Load() failed: %v", err)
+	}
+	if len(s.Entries) != 0 {
+		t.Errorf("State has %d entries, want 0", len(s.Entries))
+	}
+}
+
+// TestRollbackUndoesChanges verifies links, backups and directories are undone in reverse order
+func TestRollbackUndoesChanges(t *testing.T) {
+	repoPath, testHome := setupJournalTest(t)
+
+	if err := BeginJournal(); err != nil {
+		t.Fatalf("BeginJournal() failed: %v", err)
+	}
+	if err := Dir(repoPath, repoPath); err != nil {
+		t.Fatalf("Dir() failed: %v", err)
+	}
+	if !isSymlink(filepath.Join(testHome, ".config", "app", "config")) {
+		t.Fatal("Nested file was not linked")
+	}
+	if err := CloseJournal(); err != nil {
+		t.Fatalf("CloseJournal() failed: %v", err)
+	}
+	if err := Rollback(); err != nil {
+		t.Fatalf("Rollback() failed: %v", err)
+	}
+	assertUndone(t, repoPath, testHome)
+
+	if err := Undo(); err == nil {
+		t.Error("Undo() should fail after the journal was rolled back")
+	}
+}
+
+// TestUndoRollsBackLastApply verifies the journal can be undone after linking completed
+func TestUndoRollsBackLastApply(t *testing.T) {
+	repoPath, testHome := setupJournalTest(t)
+
+	if err := BeginJournal(); err != nil {
+		t.Fatalf("BeginJournal() failed: %v", err)
+	}
+	if err := Dir(repoPath, repoPath); err != nil {
+		t.Fatalf("Dir() failed: %v", err)
+	}
+	if err := CloseJournal(); err != nil {
+		t.Fatalf("CloseJournal() failed: %v", err)
+	}
+	journal = nil
+
+	// A link that was changed after the apply is not removed
+	extPath := filepath.Join(testHome, ".config", "app", "config")
+	if err := os.Remove(extPath); err != nil {
+		t.Fatalf("Failed to remove link: %v", err)
+	}
+	if err := os.WriteFile(extPath, []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to replace link: %v", err)
+	}
+	if err := Undo(); err == nil {
+		t.Error("Undo() should fail to remove a changed file")
+	}
+
+	if err := os.Remove(extPath); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if err := Undo(); err != nil {
+		t.Fatalf("Undo() failed: %v", err)
+	}
+	assertUndone(t, repoPath, testHome)
+}
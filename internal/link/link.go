@@ -13,8 +13,13 @@ import (
 )
 
 var (
+	// DryRun prints the actions that would be taken instead of modifying the filesystem or the git index
+	DryRun = false
+
+	// backupDisabled and ignoreFilesRegex are set by environment variables,
+	// which override the repository's configuration
 	backupDisabled   = false
-	ignoreFilesRegex = regexp.MustCompile("a^") // Do not match anything by default
+	ignoreFilesRegex *regexp.Regexp
 )
 
 // Unlink unlinks the given paths
@@ -22,11 +27,37 @@ func Unlink(repoPath string, paths []string) error {
 	return syncLinks(repoPath, paths, UnlinkDir, UnlinkFile)
 }
 
-// Link unlinks the given paths
+// Link links the given paths
 func Link(repoPath string, paths []string) error {
+	if DryRun {
+		return dryRunLink(repoPath, paths)
+	}
 	return syncLinks(repoPath, paths, Dir, File)
 }
 
+// dryRunLink walks the given paths at their external locations, because they
+// have not been copied into the repository during a dry run
+func dryRunLink(repoPath string, paths []string) error {
+	for _, extPath := range paths {
+		err := filepath.Walk(extPath, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() || strings.HasSuffix(p, ".gog") {
+				return nil
+			}
+			return File(repoPath, repository.ToInternalPath(repoPath, p))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type syncFunc func(string, string) error
 
 func syncLinks(repoPath string, paths []string, updateDir, updateFile syncFunc) error {
@@ -56,133 +87,285 @@ func syncLinks(repoPath string, paths []string, updateDir, updateFile syncFunc)
 // Dir recursively creates symbolic links from a repository directory's files
 // to the root filesystem
 func Dir(repoPath, intPath string) error {
-	return filepath.Walk(intPath, func(p string, info os.FileInfo, err error) error {
+	return linkDir(repoPath, intPath, func(p string) error {
+		return File(repoPath, p)
+	})
+}
+
+// Repositories links the contents of several repositories, which are given in
+// order of decreasing priority. When more than one repository contains the same
+// file, it is linked to the highest priority repository and the others are reported.
+func Repositories(repoPaths []string) error {
+	owners := make(map[string]string)
+	for _, repoPath := range repoPaths {
+		err := linkDir(repoPath, repoPath, func(p string) error {
+			if isIgnored(repoPath, p, false) || !isSelectedVariant(p) {
+				return nil
+			}
+			extPath := repository.ToExternalPath(repoPath, p)
+			if owner, ok := owners[extPath]; ok {
+				printOverridden(p, extPath, owner)
+				results.Skipped++
+				return nil
+			}
+			owners[extPath] = repoPath
+			return File(repoPath, p)
+		})
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		switch p {
-		case repoPath:
-			return nil
-		case filepath.Join(repoPath, ".git"):
-			return filepath.SkipDir
-		}
-
+// linkDir creates the directories below intPath on the root filesystem and
+// calls linkFile for each file
+func linkDir(repoPath, intPath string, linkFile func(string) error) error {
+	return walk(repoPath, intPath, func(p string, info os.FileInfo) error {
 		if info.IsDir() {
+			if isIgnored(repoPath, p, true) {
+				return filepath.SkipDir
+			}
 			extPath := repository.ToExternalPath(repoPath, p)
-			if isSymlink(extPath) {
-				ok, err := backup(extPath)
+			if isFolded(repoPath, p) {
+				ok, err := foldDir(repoPath, p, extPath)
+				if err != nil {
+					if err := fail(p, extPath, err); err != nil {
+						return err
+					}
+					return filepath.SkipDir
+				}
+				if ok {
+					return filepath.SkipDir
+				}
+			}
+			if linkTarget, _ := readlink(extPath); linkTarget != "" && isRecordedLink(extPath, linkTarget) && isDir(extPath) {
+				// The directory was folded, but its files are now linked individually
+				if err := unfold(extPath); err != nil {
+					if err := fail(p, extPath, fmt.Errorf("failed to unfold %s: %w", extPath, err)); err != nil {
+						return err
+					}
+					return filepath.SkipDir
+				}
+			} else if isSymlink(extPath) {
+				ok, err := backup(p, extPath)
 				if !ok {
-					printError(p, fmt.Errorf("backup failed, skipping directory: %w", err))
+					if err := fail(p, extPath, fmt.Errorf("backup failed, skipping directory: %w", err)); err != nil {
+						return err
+					}
 					return filepath.SkipDir
 				}
 			}
 
-			if err := os.MkdirAll(extPath, 0755); err != nil {
-				printError(p, fmt.Errorf("failed to create directory %s: %w", extPath, err))
+			if err := mkdirAll(extPath); err != nil {
+				if err := fail(p, extPath, fmt.Errorf("failed to create directory %s: %w", extPath, err)); err != nil {
+					return err
+				}
 				return filepath.SkipDir
 			}
 			return nil
 		}
-		return File(repoPath, p)
+		return linkFile(p)
+	})
+}
+
+// walk calls fn for every file and directory below intPath, except for the
+// repository root and its .git directory, until linking is interrupted
+func walk(repoPath, intPath string, fn func(string, os.FileInfo) error) error {
+	// Variants are selected by listing each file's directory
+	defer repository.CacheDirNames()()
+	return filepath.Walk(intPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if interrupted.Load() {
+			return ErrInterrupted
+		}
+
+		switch p {
+		case repoPath:
+			return nil
+		case filepath.Join(repoPath, ".git"):
+			return filepath.SkipDir
+		}
+		return fn(p, info)
 	})
 }
 
 // File creates a symbolic link from a repository file to the root filesystem.
-// File declares an `error` return type to match the signature of `Dir`, but
-// usually print an error message and return nil.
+// File reports an error and returns nil if the file cannot be linked, unless
+// FailFast is true. Use `Results` to check whether any file failed.
 func File(repoPath, intPath string) error {
-	if ignoreFilesRegex.MatchString(strings.TrimPrefix(intPath, repoPath+"/")) {
+	if isIgnored(repoPath, intPath, false) {
+		results.Skipped++
 		return nil
 	}
-	switch intPath {
-	case filepath.Join(repoPath, ".gitignore"):
-		return nil
-	case filepath.Join(repoPath, "LICENSE"):
-		return nil
-	case filepath.Join(repoPath, "README.md"):
+	selected, hasVariants := repository.SelectVariant(intPath)
+	if _, err := os.Lstat(intPath); err != nil || !hasVariants {
+		// The file is not in the repository yet during a dry run of `gog add`,
+		// or it is the only variant
+		selected = intPath
+	}
+	if selected != intPath {
+		// Another variant of the file is linked on this machine
+		results.Skipped++
 		return nil
 	}
 
 	extPath := repository.ToExternalPath(repoPath, intPath)
-	err := os.Symlink(intPath, extPath)
-	if err == nil {
-		// Success
-		printLinked(intPath, extPath)
-		addToGit(repoPath, intPath)
-		return nil
+	if hasVariants {
+		printSelectedVariant(intPath, extPath)
 	}
-	if !os.IsExist(err) {
-		// We cannot recover from an error other than extPath already existing, in which case we can back it up.
-		return fmt.Errorf("failed to create symlink from %s to %s: %w", extPath, intPath, err)
+	if folded := foldedParent(extPath); folded != "" {
+		if linkTarget, _ := readlink(folded); strings.HasPrefix(intPath, linkTarget+"/") {
+			// The file is linked by its folded parent directory
+			return addToGit(repoPath, intPath)
+		}
+		if err := unfold(folded); err != nil {
+			return fail(intPath, extPath, fmt.Errorf("failed to unfold %s: %w", folded, err))
+		}
+	}
+	if err := applyMetadata(repoPath, intPath); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	if repository.IsTemplate(intPath) {
+		return renderFile(repoPath, intPath, extPath)
+	}
+	if repository.IsEncrypted(intPath) {
+		return decryptFile(repoPath, intPath, extPath)
+	}
+	switch mode(repoPath, intPath) {
+	case repository.ModeCopy:
+		return copyFile(repoPath, intPath, extPath)
+	case repository.ModeHardlink:
+		return hardlinkFile(repoPath, intPath, extPath)
 	}
-
 	extFileInfo, err := os.Lstat(extPath)
 	if err != nil {
-		printError(intPath, fmt.Errorf("failed to stat %s: %w", extPath, err))
-		return nil
+		if os.IsNotExist(err) {
+			err = symlink(intPath, extPath)
+		}
+		if err != nil {
+			// We cannot recover from an error other than extPath already existing, in which case we can back it up.
+			return fail(intPath, extPath, fmt.Errorf("failed to create symlink from %s to %s: %w", extPath, intPath, err))
+		}
+		printLinked(intPath, extPath)
+		return linked(repoPath, intPath, extPath)
 	}
 	if extFileInfo.IsDir() {
-		printError(intPath, fmt.Errorf("cannot create symlink: %s exists and is a directory (remove the directory or use a different location)", extPath))
-		return nil
+		return fail(intPath, extPath, fmt.Errorf("cannot create symlink: %s exists and is a directory (remove the directory or use a different location)", extPath))
 	}
 
-	shouldBackup := !backupDisabled
-
 	// Check if symlink already points to the correct target
-	linkTarget, err := os.Readlink(extPath)
+	linkTarget, err := readlink(extPath)
 	if err == nil && linkTarget == intPath {
 		// Already linked to the correct location - no need to recreate
-		addToGit(repoPath, intPath)
+		return linked(repoPath, intPath, extPath)
+	}
+	if isReplaced(repoPath, intPath, extPath) {
+		printReplacedByFile(intPath, extPath)
+		results.Skipped++
 		return nil
 	}
 
+	if err := clearExtPath(repoPath, intPath, extPath); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	if err = symlink(intPath, extPath); err != nil {
+		return fail(intPath, extPath, fmt.Errorf("failed to create symlink from %s to %s: %w", extPath, intPath, err))
+	}
+	printLinked(intPath, extPath)
+	return linked(repoPath, intPath, extPath)
+}
+
+// linked records a symbolic link, and then stages the repository file
+func linked(repoPath, intPath, extPath string) error {
+	if err := recordSymlink(repoPath, intPath, extPath); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	return addToGit(repoPath, intPath)
+}
+
+// clearExtPath backs up or removes the file at extPath, so that it can be
+// replaced by a link to intPath. Conflicts with files that were not created by
+// gog are resolved by the conflict policy.
+func clearExtPath(repoPath, intPath, extPath string) error {
+	conflict := true
+
 	// Try to resolve the symlink to check if it's broken
+	linkTarget, _ := readlink(extPath)
 	_, evalErr := filepath.EvalSymlinks(extPath)
-	if evalErr != nil {
+	replacedTarget := ""
+	switch {
+	case evalErr == nil && linkTarget != "" && isRecordedLink(extPath, linkTarget):
+		// The link was created by gog for another repository, so it can be recreated at any time,
+		// unless that repository takes precedence
+		ownerRepoPath := filepath.Join(repository.BaseDir, repositoryName(linkTarget))
+		if ownerRepoPath != repoPath && repository.HasHigherPriority(ownerRepoPath, repoPath) {
+			printLinkedByHigherPriority(intPath, extPath, ownerRepoPath)
+			return errConflictSkipped
+		}
+		replacedTarget = linkTarget
+		conflict = false
+	case evalErr == nil && linkTarget == "" && isUnmodified(repoPath, extPath):
+		// The file was copied or hard linked by gog, so it can be recreated at any time
+		conflict = false
+	case evalErr != nil:
 		// Can only recover from an error due to a broken symbolic link
 		if !os.IsNotExist(evalErr) {
-			printError(intPath, fmt.Errorf("failed to resolve symlink %s: %w", extPath, evalErr))
-			return nil
+			return fmt.Errorf("failed to resolve symlink %s: %w", extPath, evalErr)
 		}
-		shouldBackup = false
+		conflict = false
 	}
 
-	if shouldBackup {
-		ok, backupErr := backup(extPath)
-		if !ok {
-			printError(intPath, fmt.Errorf("backup failed, skipping: %w", backupErr))
-			return nil
-		}
-	} else {
-		// Either extPath is a broken symbolic link or backups are disabled
-		if err = os.Remove(extPath); err != nil {
-			printError(intPath, fmt.Errorf("failed to remove %s: %w", extPath, err))
-			return nil
+	if conflict {
+		switch resolveConflict(repoPath, intPath, extPath) {
+		case ConflictBackup:
+			ok, err := backup(intPath, extPath)
+			if !ok {
+				return fmt.Errorf("backup failed, skipping: %w", err)
+			}
+			return recordBackup(repoPath, intPath, extPath, backupPath(extPath))
+		case ConflictSkip:
+			printConflictSkipped(intPath, extPath)
+			return errConflictSkipped
+		case ConflictAdopt:
+			return adoptConflict(intPath, extPath)
+		case ConflictFail:
+			return fmt.Errorf("%s exists (use --on-conflict or --interactive to resolve the conflict)", extPath)
 		}
 	}
-	if err = os.Symlink(intPath, extPath); err != nil {
-		printError(intPath, fmt.Errorf("failed to create symlink from %s to %s: %w", extPath, intPath, err))
-		return nil
+	// Either extPath is a broken symbolic link, gog can recreate it or it is overwritten
+	if err := replace(extPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", extPath, err)
+	}
+	printReplaced(intPath, extPath, replacedTarget)
+	if replacedTarget != "" {
+		// The other repository no longer manages extPath
+		return forget(filepath.Join(repository.BaseDir, repositoryName(replacedTarget)), extPath)
 	}
-	printLinked(intPath, extPath)
-	addToGit(repoPath, intPath)
 	return nil
 }
 
-func addToGit(repoPath, intPath string) {
-	if err := git.Run(repoPath, "add", "--force", intPath); err != nil {
-		printError(intPath, fmt.Errorf("failed to add %s to git: %w", intPath, err))
+// addToGit stages a linked file, and then counts it as linked
+func addToGit(repoPath, intPath string) error {
+	if DryRun {
+		printStaged(intPath)
+	} else if err := git.Run(repoPath, "add", "--force", intPath); err != nil {
+		return fail(intPath, "", fmt.Errorf("failed to add %s to git: %w", intPath, err))
 	}
+	results.Linked++
+	return nil
 }
 
-func backup(p string) (bool, error) {
+func backup(intPath, p string) (bool, error) {
 	backupPath := backupPath(p)
-	if err := os.Rename(p, backupPath); err != nil {
+	if err := rename(p, backupPath); err != nil {
 		// It's better to attempt to rename and fail if
 		// os.Rename will overwrite existing files, but not existing directories
 		return false, fmt.Errorf("failed to rename %s to %s: %w", p, backupPath, err)
 	}
+	printBackedUp(intPath, p, backupPath)
 	return true, nil
 }
 
@@ -192,6 +375,63 @@ func backupPath(p string) string {
 	return filepath.Join(dirname, fmt.Sprintf(".%s.gog", basename))
 }
 
+// config returns the configuration of the given repository. Invalid
+// configuration files are reported when the repository is resolved by
+// `repository.RootPath`, so the default configuration is used here instead.
+func config(repoPath string) *repository.Config {
+	c, err := repository.LoadConfig(repoPath)
+	if err != nil {
+		return &repository.Config{}
+	}
+	return c
+}
+
+// mode returns how the given repository file is linked
+func mode(repoPath, intPath string) string {
+	return config(repoPath).ModeFor(strings.TrimPrefix(intPath, repoPath+"/"))
+}
+
+// isSelectedVariant returns true if intPath is linked on the current machine,
+// rather than another variant of the same file
+func isSelectedVariant(intPath string) bool {
+	selected, _ := repository.SelectVariant(intPath)
+	return selected == intPath
+}
+
+// isIgnored returns true if the given repository file or directory should never be linked
+func isIgnored(repoPath, intPath string, isDir bool) bool {
+	relPath := strings.TrimPrefix(intPath, repoPath+"/")
+	switch relPath {
+	case repository.ConfigFileName, repository.IgnoreFileName, repository.MetadataFileName:
+		return true
+	}
+	if !isDir {
+		if ignoreFilesRegex != nil {
+			if ignoreFilesRegex.MatchString(relPath) {
+				return true
+			}
+		} else if config(repoPath).IsIgnored(relPath) {
+			return true
+		}
+	}
+	return config(repoPath).IsIgnoredByFile(relPath, isDir)
+}
+
+func isDir(p string) bool {
+	fileInfo, err := os.Stat(p)
+	return err == nil && fileInfo.IsDir()
+}
+
+// readlink returns the absolute target of the symbolic link at p. Links within
+// a target root are relative to their directory.
+func readlink(p string) (string, error) {
+	linkTarget, err := os.Readlink(p)
+	if err != nil || filepath.IsAbs(linkTarget) {
+		return linkTarget, err
+	}
+	return filepath.Join(filepath.Dir(p), linkTarget), nil
+}
+
 func isSymlink(p string) bool {
 	fileInfo, err := os.Lstat(p)
 	if err != nil {
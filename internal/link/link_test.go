@@ -5,9 +5,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
 )
 
 // setupTestRepo creates a temporary test repository structure with git initialized
@@ -42,7 +45,14 @@ func setupTestRepo(t *testing.T) (repoPath string, cleanup func()) {
 		}
 	}
 
+	// Keep gog's state files within the temporary directory
+	originalBaseDir := repository.BaseDir
+	repository.BaseDir = tmpDir
+
 	cleanup = func() {
+		// Unsaved states would otherwise be saved into the removed directory by a later test
+		state.Discard()
+		repository.BaseDir = originalBaseDir
 		os.RemoveAll(tmpDir)
 	}
 
@@ -483,3 +493,289 @@ func TestIsSymlink(t *testing.T) {
 		})
 	}
 }
+
+// TestFileDryRunDoesNotModifyFilesystem verifies that dry runs leave existing files in place
+func TestFileDryRunDoesNotModifyFilesystem(t *testing.T) {
+	originalDryRun := DryRun
+	DryRun = true
+	defer func() { DryRun = originalDryRun }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	// Set up home directory for testing
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	// Create a test file in the repo
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if mkdirErr := os.MkdirAll(filepath.Dir(intPath), 0755); mkdirErr != nil {
+		t.Fatalf("Failed to create dir: %v", mkdirErr)
+	}
+	if writeErr := os.WriteFile(intPath, []byte("new content"), 0644); writeErr != nil {
+		t.Fatalf("Failed to create test file: %v", writeErr)
+	}
+
+	extPath := repository.ToExternalPath(repoPath, intPath)
+	if writeErr := os.WriteFile(extPath, []byte("existing content"), 0644); writeErr != nil {
+		t.Fatalf("Failed to create existing file: %v", writeErr)
+	}
+
+	if err = File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	if isSymlink(extPath) {
+		t.Error("Dry run should not create a symlink")
+	}
+	if _, err := os.Lstat(backupPath(extPath)); !os.IsNotExist(err) {
+		t.Error("Dry run should not create a backup")
+	}
+}
+
+// TestAddDryRunReportsEveryStep verifies a dry run of `gog add` reports that the
+// file would be copied, backed up, linked and staged, although it is not in the repository yet
+func TestAddDryRunReportsEveryStep(t *testing.T) {
+	originalDryRun := DryRun
+	DryRun = true
+	repository.DryRun = true
+	defer func() {
+		DryRun = originalDryRun
+		repository.DryRun = originalDryRun
+	}()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+	testHome := t.TempDir()
+	defer repository.SetHomeDirForTest(repository.SetHomeDirForTest(testHome))
+
+	extPath := filepath.Join(testHome, ".bashrc")
+	if err := os.WriteFile(extPath, []byte("content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	stdout := captureStdout(t, func() {
+		if err := repository.AddPaths(repoPath, []string{extPath}); err != nil {
+			t.Fatalf("AddPaths() failed: %v", err)
+		}
+		if err := Link(repoPath, []string{extPath}); err != nil {
+			t.Fatalf("Link() failed: %v", err)
+		}
+	})
+
+	for _, step := range []string{"Would copy:", "Would back up:", "Would link:", "Would stage:"} {
+		if !strings.Contains(stdout, step) {
+			t.Errorf("Output does not contain %q:\n%s", step, stdout)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "$HOME", ".bashrc")); !os.IsNotExist(err) {
+		t.Error("Dry run should not copy the file into the repository")
+	}
+}
+
+// TestDirDryRunReportsEachDirectoryOnce verifies a dry run reports each missing
+// directory once, including the parents of a folded directory and of the home directory
+func TestDirDryRunReportsEachDirectoryOnce(t *testing.T) {
+	originalDryRun := DryRun
+	DryRun = true
+	defer func() { DryRun = originalDryRun }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+	testHome := filepath.Join(t.TempDir(), "root", "home")
+	defer repository.SetHomeDirForTest(repository.SetHomeDirForTest(testHome))
+
+	if err := os.WriteFile(filepath.Join(repoPath, repository.ConfigFileName), []byte(`fold = ["$HOME/.config/nvim"]`), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	for _, name := range []string{".config/nvim/init.lua", ".config/app.conf"} {
+		intPath := filepath.Join(repoPath, "$HOME", name)
+		if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(intPath, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	stdout := captureStdout(t, func() {
+		if err := Dir(repoPath, repoPath); err != nil {
+			t.Fatalf("Dir() failed: %v", err)
+		}
+	})
+
+	for _, dir := range []string{filepath.Dir(testHome), testHome, filepath.Join(testHome, ".config")} {
+		if n := strings.Count(stdout, "Would create directory: "+dir+"\n"); n != 1 {
+			t.Errorf("Output reports %s %d times, want once:\n%s", dir, n, stdout)
+		}
+	}
+	if _, err := os.Stat(testHome); !os.IsNotExist(err) {
+		t.Error("Dry run should not create the home directory")
+	}
+}
+
+// captureStdout returns what f prints to standard output
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer file.Close()
+	originalStdout := os.Stdout
+	os.Stdout = file
+	defer func() { os.Stdout = originalStdout }()
+
+	f()
+	content, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	return string(content)
+}
+
+// TestRepositoriesLinksHighestPriority verifies overlapping files link to the first repository
+func TestRepositoriesLinksHighestPriority(t *testing.T) {
+	highRepoPath, cleanupHigh := setupTestRepo(t)
+	defer cleanupHigh()
+	lowRepoPath, cleanupLow := setupTestRepo(t)
+	defer cleanupLow()
+
+	// Set up home directory for testing
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	for _, repoPath := range []string{highRepoPath, lowRepoPath} {
+		intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+		if mkdirErr := os.MkdirAll(filepath.Dir(intPath), 0755); mkdirErr != nil {
+			t.Fatalf("Failed to create dir: %v", mkdirErr)
+		}
+		if writeErr := os.WriteFile(intPath, []byte(repoPath), 0644); writeErr != nil {
+			t.Fatalf("Failed to create test file: %v", writeErr)
+		}
+	}
+
+	if err = Repositories([]string{highRepoPath, lowRepoPath}); err != nil {
+		t.Fatalf("Repositories() failed: %v", err)
+	}
+
+	extPath := filepath.Join(testHome, ".bashrc")
+	linkDest, err := os.Readlink(extPath)
+	if err != nil {
+		t.Fatalf("Failed to read symlink: %v", err)
+	}
+	expected := filepath.Join(highRepoPath, "$HOME", ".bashrc")
+	if linkDest != expected {
+		t.Errorf("Symlink points to %q, want %q", linkDest, expected)
+	}
+}
+
+// TestDirSkipsGogignoreFiles verifies .gogignore patterns are applied during the walk
+func TestDirSkipsGogignoreFiles(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	files := map[string]string{
+		repository.IgnoreFileName:   "docs/\nCONTRIBUTING.md\n*.sh\n!/$HOME/bin/keep.sh\n",
+		"CONTRIBUTING.md":           "contributing",
+		"README.md":                 "readme",
+		"$HOME/README.md":           "not at the repository root",
+		"docs/index.md":             "docs",
+		"$HOME/bin/install.sh":      "install",
+		"$HOME/bin/keep.sh":         "keep",
+		"$HOME/.config/docs/a.conf": "nested docs",
+		"$HOME/.bashrc":             "bashrc",
+	}
+	for name, content := range files {
+		p := filepath.Join(repoPath, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	if err := Dir(repoPath, repoPath); err != nil {
+		t.Fatalf("Dir() failed: %v", err)
+	}
+
+	linked := []string{"$HOME/README.md", "$HOME/bin/keep.sh", "$HOME/.bashrc"}
+	ignored := []string{repository.IgnoreFileName, "README.md", "CONTRIBUTING.md", "docs/index.md", "$HOME/bin/install.sh", "$HOME/.config/docs/a.conf"}
+	for _, name := range linked {
+		intPath := filepath.Join(repoPath, name)
+		if !isSymlink(repository.ToExternalPath(repoPath, intPath)) {
+			t.Errorf("%s should be linked", name)
+		}
+	}
+	for _, name := range ignored {
+		intPath := filepath.Join(repoPath, name)
+		if _, err := os.Lstat(repository.ToExternalPath(repoPath, intPath)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be linked", name)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(testHome, ".config", "docs")); !os.IsNotExist(err) {
+		t.Error("Ignored directories should not be created")
+	}
+}
+
+// TestDirLinksSelectedVariant verifies only the variant for the current machine is linked
+func TestDirLinksSelectedVariant(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	dir := filepath.Join(repoPath, "$HOME")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	selected := filepath.Join(dir, ".profile##os."+runtime.GOOS)
+	other := filepath.Join(dir, ".profile##os.plan9")
+	plain := filepath.Join(dir, ".profile")
+	for _, p := range []string{selected, other, plain} {
+		if err := os.WriteFile(p, []byte(p), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	if err := Repositories([]string{repoPath}); err != nil {
+		t.Fatalf("Repositories() failed: %v", err)
+	}
+
+	extPath := filepath.Join(testHome, ".profile")
+	if target, err := os.Readlink(extPath); err != nil || target != selected {
+		t.Errorf("%s should be linked to %s, got %q (%v)", extPath, selected, target, err)
+	}
+	for _, p := range []string{other, plain} {
+		if s := FileState(repoPath, p); s.State != StateOtherVariant {
+			t.Errorf("FileState(%q).State = %q, want %q", p, s.State, StateOtherVariant)
+		}
+	}
+	if s := FileState(repoPath, selected); s.State != StateLinked {
+		t.Errorf("FileState(%q).State = %q, want %q", selected, s.State, StateLinked)
+	}
+}
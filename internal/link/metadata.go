@@ -0,0 +1,103 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andornaut/gog/internal/privileged"
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// applyMetadata changes the permissions of a repository file to those that
+// were recorded when it was added, because git does not preserve them. The
+// repository file remains owned by the user who runs gog, so that git can read it.
+func applyMetadata(repoPath, intPath string) error {
+	fm, ok, err := fileMetadata(repoPath, intPath)
+	if err != nil || !ok {
+		return err
+	}
+	intFileInfo, err := os.Lstat(intPath)
+	if err != nil {
+		return err
+	}
+	if !fm.ModeDiffers(intFileInfo) {
+		return nil
+	}
+	drift := repository.FileMetadata{Mode: fm.Mode}.Drift(intFileInfo)
+	mode, _ := fm.FileMode()
+	if err := chmod(intPath, mode); err != nil {
+		return fmt.Errorf("failed to change the mode of %s (%s): %w", intPath, drift, err)
+	}
+	printChangedMetadata(intPath, drift)
+	return nil
+}
+
+// applyOwner changes the owner and group of a file that was copied, rendered
+// or decrypted from a repository file to those that were recorded when it was
+// added. They are only changed when gog runs as root or with --escalate, and
+// are otherwise reported by `gog status`.
+func applyOwner(repoPath, intPath, extPath string) error {
+	fm, ok, err := fileMetadata(repoPath, intPath)
+	if err != nil || !ok || (os.Geteuid() != 0 && !privileged.Enabled()) {
+		return err
+	}
+	extFileInfo, err := os.Lstat(extPath)
+	if err != nil {
+		if DryRun && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	owner := repository.FileMetadata{Owner: fm.Owner, Group: fm.Group}
+	if !owner.OwnerDiffers(extFileInfo) {
+		return nil
+	}
+	drift := owner.Drift(extFileInfo)
+	uid, gid, err := fm.IDs()
+	if err != nil {
+		return fmt.Errorf("failed to change the owner of %s: %w", extPath, err)
+	}
+	if err := chown(extPath, uid, gid); err != nil {
+		return fmt.Errorf("failed to change the owner of %s (%s): %w", extPath, drift, err)
+	}
+	// Changing the owner clears the setuid and setgid bits
+	if err := chmod(extPath, extFileInfo.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return fmt.Errorf("failed to change the mode of %s: %w", extPath, err)
+	}
+	printChangedMetadata(extPath, drift)
+	return nil
+}
+
+// metadataDrift describes how a repository file's permissions, and the owner
+// and group of the file at its external path, differ from those that were
+// recorded when it was added. A link cannot have another owner than the
+// repository file, so the owner of a linked file always drifts.
+func metadataDrift(repoPath, intPath, extPath string) string {
+	fm, ok, err := fileMetadata(repoPath, intPath)
+	if err != nil || !ok {
+		return ""
+	}
+	intFileInfo, err := os.Lstat(intPath)
+	if err != nil {
+		return ""
+	}
+	drift := []string{repository.FileMetadata{Mode: fm.Mode}.Drift(intFileInfo)}
+	ownerFileInfo := intFileInfo
+	if isCopy(repoPath, intPath, extPath) {
+		if ownerFileInfo, err = os.Lstat(extPath); err != nil {
+			return drift[0]
+		}
+	}
+	drift = append(drift, repository.FileMetadata{Owner: fm.Owner, Group: fm.Group}.Drift(ownerFileInfo))
+	return strings.Trim(strings.Join(drift, ", "), ", ")
+}
+
+func fileMetadata(repoPath, intPath string) (repository.FileMetadata, bool, error) {
+	m, err := repository.LoadMetadata(repoPath)
+	if err != nil {
+		return repository.FileMetadata{}, false, err
+	}
+	fm, ok := m[strings.TrimPrefix(intPath, repoPath+"/")]
+	return fm, ok, nil
+}
@@ -0,0 +1,161 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestFileAppliesRecordedMode verifies the recorded mode is restored on the repository file, and drift is reported
+func TestFileAppliesRecordedMode(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	metadata := "[\"$HOME/.netrc\"]\nmode = \"0600\"\n"
+	if err := os.WriteFile(filepath.Join(repoPath, repository.MetadataFileName), []byte(metadata), 0644); err != nil {
+		t.Fatalf("Failed to create metadata file: %v", err)
+	}
+	// git checks files out with the default mode
+	intPath := filepath.Join(repoPath, "$HOME", ".netrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("machine example.com"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if s := FileState(repoPath, intPath); s.Drift != "mode 0644, want 0600" || s.InSync() {
+		t.Errorf("FileState() drift = %q, want the mode to be reported", s.Drift)
+	}
+
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	intFileInfo, err := os.Stat(intPath)
+	if err != nil {
+		t.Fatalf("Failed to stat repository file: %v", err)
+	}
+	if intFileInfo.Mode().Perm() != 0600 {
+		t.Errorf("Repository file mode = %v, want 0600", intFileInfo.Mode().Perm())
+	}
+	if s := FileState(repoPath, intPath); s.Drift != "" || !s.InSync() {
+		t.Errorf("FileState() = %+v, want the file to be in sync", s)
+	}
+}
+
+// otherOwner returns a user that does not run the tests
+func otherOwner(t *testing.T) string {
+	t.Helper()
+	owner := "root"
+	if os.Geteuid() == 0 {
+		owner = "nobody"
+	}
+	if _, err := user.Lookup(owner); err != nil {
+		t.Skipf("User %s does not exist", owner)
+	}
+	return owner
+}
+
+// writeOwnedFile creates a repository file whose recorded owner is not the user who runs the tests
+func writeOwnedFile(t *testing.T, repoPath, owner, config string) string {
+	t.Helper()
+	metadata := "[\"$HOME/.netrc\"]\nmode = \"0644\"\nowner = \"" + owner + "\"\n"
+	if err := os.WriteFile(filepath.Join(repoPath, repository.MetadataFileName), []byte(metadata), 0644); err != nil {
+		t.Fatalf("Failed to create metadata file: %v", err)
+	}
+	if config != "" {
+		if err := os.WriteFile(filepath.Join(repoPath, repository.ConfigFileName), []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to create config file: %v", err)
+		}
+	}
+	intPath := filepath.Join(repoPath, "$HOME", ".netrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("machine example.com"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	return intPath
+}
+
+// TestFileReportsOwnerOfLink verifies the repository file keeps its owner, so
+// a linked file reports the recorded owner as drift
+func TestFileReportsOwnerOfLink(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	owner := otherOwner(t)
+	intPath := writeOwnedFile(t, repoPath, owner, "")
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	intFileInfo, err := os.Stat(intPath)
+	if err != nil {
+		t.Fatalf("Failed to stat repository file: %v", err)
+	}
+	if uid := intFileInfo.Sys().(*syscall.Stat_t).Uid; int(uid) != os.Geteuid() {
+		t.Errorf("Repository file owner = %d, want %d", uid, os.Geteuid())
+	}
+	if s := FileState(repoPath, intPath); s.State != StateLinked || !strings.Contains(s.Drift, "want "+owner) {
+		t.Errorf("FileState() = %+v, want a link whose owner drifts", s)
+	}
+}
+
+// TestFileAppliesOwnerToCopy verifies a copy outside the repository is given
+// the recorded owner when gog runs as root
+func TestFileAppliesOwnerToCopy(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing the owner of a file requires root")
+	}
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	owner := otherOwner(t)
+	intPath := writeOwnedFile(t, repoPath, owner, "[modes]\n\"$HOME/.netrc\" = \"copy\"\n")
+	if s := FileState(repoPath, intPath); s.Drift != "" {
+		t.Errorf("FileState() drift = %q, want none before the file is copied", s.Drift)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	u, err := user.Lookup(owner)
+	if err != nil {
+		t.Fatalf("Failed to look up %s: %v", owner, err)
+	}
+	extFileInfo, err := os.Stat(filepath.Join(testHome, ".netrc"))
+	if err != nil {
+		t.Fatalf("Failed to stat copy: %v", err)
+	}
+	if uid := fmt.Sprint(extFileInfo.Sys().(*syscall.Stat_t).Uid); uid != u.Uid {
+		t.Errorf("Copy owner = %s, want %s", uid, u.Uid)
+	}
+	intFileInfo, err := os.Stat(intPath)
+	if err != nil {
+		t.Fatalf("Failed to stat repository file: %v", err)
+	}
+	if uid := intFileInfo.Sys().(*syscall.Stat_t).Uid; uid != 0 {
+		t.Errorf("Repository file owner = %d, want 0", uid)
+	}
+	if s := FileState(repoPath, intPath); s.Drift != "" || !s.InSync() {
+		t.Errorf("FileState() = %+v, want the copy to be in sync", s)
+	}
+}
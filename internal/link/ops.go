@@ -0,0 +1,181 @@
+package link
+
+import (
+	"os"
+
+	"github.com/andornaut/gog/internal/git"
+	"github.com/andornaut/gog/internal/privileged"
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// symlink creates a symbolic link at extPath which points to intPath
+func symlink(intPath, extPath string) error {
+	linkTarget, err := repository.LinkTarget(intPath, extPath)
+	if err != nil {
+		return err
+	}
+	if DryRun {
+		// The caller reports the link
+		return nil
+	}
+	if err := privileged.Symlink(linkTarget, extPath); err != nil {
+		return err
+	}
+	return journalCreate(extPath)
+}
+
+// hardlink creates a hard link at extPath to intPath
+func hardlink(intPath, extPath string) error {
+	if DryRun {
+		// The caller reports the link
+		return nil
+	}
+	if err := privileged.Link(intPath, extPath); err != nil {
+		return err
+	}
+	return journalCreate(extPath)
+}
+
+// replace removes extPath so that it can be replaced by a link
+func replace(extPath string) error {
+	if DryRun {
+		// The caller reports the replacement
+		return nil
+	}
+	return remove(extPath)
+}
+
+// replaceWithCopy replaces the link at extPath with a copy of intPath
+func replaceWithCopy(intPath, extPath string) error {
+	if DryRun {
+		printCopied(intPath, extPath)
+		return nil
+	}
+	if err := remove(extPath); err != nil {
+		return err
+	}
+	if err := privileged.CopyFile(intPath, extPath); err != nil {
+		return err
+	}
+	return journalCreate(extPath)
+}
+
+// writeFile writes content to a file at p, which is replaced if it exists
+func writeFile(p string, content []byte, perm os.FileMode) error {
+	if DryRun {
+		// The caller reports the write
+		return nil
+	}
+	return overwrite(p, func() error {
+		return privileged.WriteFile(p, content, perm)
+	})
+}
+
+// writeCopy replaces the file at dst with a copy of src
+func writeCopy(src, dst string) error {
+	if DryRun {
+		// The caller reports the copy
+		return nil
+	}
+	return overwrite(dst, func() error {
+		return privileged.CopyFile(src, dst)
+	})
+}
+
+// chmod changes the permissions of the file at p
+func chmod(p string, perm os.FileMode) error {
+	if DryRun {
+		// Permission changes are not reported
+		return nil
+	}
+	return privileged.Chmod(p, perm)
+}
+
+// chown changes the owner and group of the file at p, or neither if they are -1
+func chown(p string, uid, gid int) error {
+	if DryRun {
+		// The caller reports the change
+		return nil
+	}
+	return privileged.Chown(p, uid, gid)
+}
+
+// dryRunDirs are the directories that a dry run reported that it would create,
+// so that each of them is reported once
+var dryRunDirs = make(map[string]bool)
+
+func mkdirAll(p string) error {
+	if DryRun {
+		dirs := missingDirs(p)
+		if isSymlink(p) {
+			dirs = append(dirs, p)
+		}
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if !dryRunDirs[dirs[i]] {
+				dryRunDirs[dirs[i]] = true
+				printCreatedDirectory(dirs[i])
+			}
+		}
+		return nil
+	}
+	dirs := missingDirs(p)
+	if err := privileged.MkdirAll(p, 0755); err != nil {
+		return err
+	}
+	return journalMkdir(dirs)
+}
+
+func removeFromGit(repoPath, intPath string) error {
+	if DryRun {
+		printUnstaged(intPath)
+		return nil
+	}
+	return git.Run(repoPath, "rm", "-qf", intPath)
+}
+
+// removeLink removes a symbolic link that was created by gog
+func removeLink(extPath string) error {
+	if DryRun {
+		// The caller reports the removal
+		return nil
+	}
+	return remove(extPath)
+}
+
+func rename(oldPath, newPath string) error {
+	if DryRun {
+		// The caller reports the rename
+		return nil
+	}
+	if err := privileged.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	return journalRename(oldPath, newPath)
+}
+
+// remove removes the file, symbolic link or empty directory at p, and journals it
+func remove(p string) error {
+	e, err := journalRemoval(p)
+	if err != nil {
+		return err
+	}
+	if err := privileged.Remove(p); err != nil {
+		return err
+	}
+	return addRemoval(e)
+}
+
+// overwrite replaces the file at p by calling write, and journals it
+func overwrite(p string, write func() error) error {
+	e, err := journalRemoval(p)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	if err := addRemoval(e); err != nil {
+		return err
+	}
+	return journalCreate(p)
+}
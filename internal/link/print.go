@@ -2,22 +2,270 @@ package link
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/andornaut/gog/internal/output"
+	"github.com/andornaut/gog/internal/repository"
 )
 
-func printError(p string, err error) {
-	fmt.Fprintf(os.Stderr, "ERROR %s %s\n", p, err)
+func printError(intPath, extPath string, err error) {
+	e := newEvent(output.ActionError, intPath, extPath)
+	e.Reason = err.Error()
+	output.EmitError(e, fmt.Sprintf("ERROR %s %s", intPath, err))
+}
+
+func printDryRun(e output.Event, format string, a ...any) {
+	output.Emit(e, fmt.Sprintf("Would "+format, a...))
+}
+
+func printAdopted(intPath, extPath string) {
+	e := newEvent(output.ActionAdopted, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "adopt: %s -> %s", extPath, escapePathVar(intPath))
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Adopted: %s -> %s", extPath, escapePathVar(intPath)))
+}
+
+func printBackedUp(intPath, extPath, backupPath string) {
+	e := newEvent(output.ActionBackedUp, intPath, extPath)
+	e.BackupPath = backupPath
+	if DryRun {
+		printDryRun(e, "back up: %s -> %s", extPath, backupPath)
+		return
+	}
+	output.Emit(e, "")
+}
+
+func printCopied(intPath, extPath string) {
+	printDryRun(newEvent(output.ActionCopied, intPath, extPath), "replace: %s with a copy of %s", extPath, escapePathVar(intPath))
+}
+
+func printWroteCopy(intPath, extPath string) {
+	e := newEvent(output.ActionCopied, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "copy: %s -> %s", escapePathVar(intPath), extPath)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Copied: %s -> %s", escapePathVar(intPath), extPath))
+}
+
+func printCreatedDirectory(extPath string) {
+	printDryRun(newEvent(output.ActionCreatedDirectory, "", extPath), "create directory: %s", extPath)
 }
 
 func printLinked(intPath string, extPath string) {
-	fmt.Printf("%s -> %s\n", extPath, escapeHomeVar(intPath))
+	e := newEvent(output.ActionLinked, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "link: %s -> %s", extPath, escapePathVar(intPath))
+		return
+	}
+	output.Emit(e, fmt.Sprintf("%s -> %s", extPath, escapePathVar(intPath)))
+}
+
+func printModified(intPath, extPath string) {
+	e := newEvent(output.ActionSkipped, intPath, extPath)
+	e.Reason = "modified since it was copied; run `gog sync` to copy the changes into the repository"
+	output.Emit(e, fmt.Sprintf("Skipped: %s (%s)", extPath, e.Reason))
+}
+
+func printHardlinked(intPath, extPath string) {
+	e := newEvent(output.ActionLinked, intPath, extPath)
+	e.Reason = "hard link"
+	if DryRun {
+		printDryRun(e, "hard link: %s => %s", extPath, escapePathVar(intPath))
+		return
+	}
+	output.Emit(e, fmt.Sprintf("%s => %s", extPath, escapePathVar(intPath)))
+}
+
+func printHardlinkFallback(intPath, extPath string) {
+	e := newEvent(output.ActionCopied, intPath, extPath)
+	e.Reason = "different device"
+	output.Emit(e, fmt.Sprintf("Cannot hard link %s to %s on a different device, copying instead", extPath, escapePathVar(intPath)))
+}
+
+func printReplacedByFile(intPath, extPath string) {
+	e := newEvent(output.ActionSkipped, intPath, extPath)
+	e.Reason = "the link was replaced by a regular file; run `gog adopt` to copy it into the repository"
+	output.Emit(e, fmt.Sprintf("Skipped: %s (%s)", extPath, e.Reason))
+}
+
+func printChangedMetadata(intPath, drift string) {
+	e := newEvent(output.ActionChanged, intPath, "")
+	e.Reason = drift
+	if DryRun {
+		printDryRun(e, "change: %s (%s)", escapePathVar(intPath), drift)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Changed: %s (%s)", escapePathVar(intPath), drift))
+}
+
+func printConflictSkipped(intPath, extPath string) {
+	e := newEvent(output.ActionSkipped, intPath, extPath)
+	e.Reason = "the file exists"
+	if DryRun {
+		printDryRun(e, "skip: %s (%s)", extPath, e.Reason)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Skipped: %s (%s)", extPath, e.Reason))
+}
+
+func printNotFolded(intPath, extPath, reason string) {
+	e := newEvent(output.ActionSkipped, intPath, extPath)
+	e.Reason = fmt.Sprintf("cannot fold the directory: %s", reason)
+	output.Emit(e, fmt.Sprintf("Cannot fold %s into %s (%s), linking its files instead", extPath, escapePathVar(intPath), reason))
+}
+
+func printOverridden(intPath, extPath, ownerRepoPath string) {
+	e := newEvent(output.ActionSkipped, intPath, extPath)
+	e.Reason = fmt.Sprintf("overridden by repository %s", filepath.Base(ownerRepoPath))
+	output.Emit(e, fmt.Sprintf("Skipped: %s -> %s (%s)", extPath, escapePathVar(intPath), e.Reason))
+}
+
+func printLinkedByHigherPriority(intPath, extPath, ownerRepoPath string) {
+	e := newEvent(output.ActionSkipped, intPath, extPath)
+	e.Reason = fmt.Sprintf("linked by higher-priority repository %s", filepath.Base(ownerRepoPath))
+	if DryRun {
+		printDryRun(e, "skip: %s (%s)", extPath, e.Reason)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Skipped: %s (%s)", extPath, e.Reason))
+}
+
+func printPruned(intPath, extPath string) {
+	e := newEvent(output.ActionPruned, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "remove stale link: %s -> %s", extPath, escapePathVar(intPath))
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Removed stale link: %s -> %s", extPath, escapePathVar(intPath)))
+}
+
+func printRemovedBackup(backupPath string) {
+	e := newEvent(output.ActionRemoved, "", "")
+	e.BackupPath = backupPath
+	if DryRun {
+		printDryRun(e, "remove: %s", backupPath)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Removed: %s", backupPath))
+}
+
+func printDecrypted(intPath, extPath string) {
+	e := newEvent(output.ActionDecrypted, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "decrypt: %s -> %s", escapePathVar(intPath), extPath)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Decrypted: %s -> %s", escapePathVar(intPath), extPath))
+}
+
+func printRendered(intPath, extPath string) {
+	e := newEvent(output.ActionRendered, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "render: %s -> %s", escapePathVar(intPath), extPath)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Rendered: %s -> %s", escapePathVar(intPath), extPath))
+}
+
+func printReplaced(intPath, extPath, linkTarget string) {
+	e := newEvent(output.ActionReplaced, intPath, extPath)
+	if linkTarget != "" {
+		e.Reason = fmt.Sprintf("linked to %s", linkTarget)
+	}
+	if DryRun {
+		printDryRun(e, "replace: %s", extPath)
+		return
+	}
+	if linkTarget != "" {
+		output.Emit(e, fmt.Sprintf("Replaced: %s -> %s (now linked to %s)", extPath, escapePathVar(linkTarget), escapePathVar(intPath)))
+	}
+}
+
+func printUnfolded(intPath, extPath string) {
+	e := newEvent(output.ActionUnfolded, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "unfold: %s", extPath)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Unfolded: %s", extPath))
+}
+
+func printUndone(extPath, action string) {
+	e := newEvent(output.ActionUndone, "", extPath)
+	e.Reason = action
+	if DryRun {
+		printDryRun(e, "undo: %s (%s)", extPath, action)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Undone: %s (%s)", extPath, action))
+}
+
+func printRestored(backupPath, extPath string) {
+	e := newEvent(output.ActionRestored, "", extPath)
+	e.BackupPath = backupPath
+	if DryRun {
+		printDryRun(e, "restore: %s -> %s", backupPath, extPath)
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Restored: %s -> %s", backupPath, extPath))
+}
+
+func printSelectedVariant(intPath, extPath string) {
+	output.Emit(newEvent(output.ActionSelected, intPath, extPath), fmt.Sprintf("Selected variant: %s -> %s", extPath, escapePathVar(intPath)))
+}
+
+func printStaged(intPath string) {
+	printDryRun(newEvent(output.ActionStaged, intPath, ""), "stage: %s", escapePathVar(intPath))
+}
+
+func printSynced(intPath, extPath string) {
+	e := newEvent(output.ActionSynced, intPath, extPath)
+	if DryRun {
+		printDryRun(e, "copy: %s -> %s", extPath, escapePathVar(intPath))
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Synced: %s -> %s", extPath, escapePathVar(intPath)))
 }
 
 func printUnLinked(intPath string) {
-	fmt.Printf("Removed: %s\n", escapeHomeVar(intPath))
+	e := newEvent(output.ActionUnlinked, intPath, "")
+	if DryRun {
+		printDryRun(e, "remove: %s", escapePathVar(intPath))
+		return
+	}
+	output.Emit(e, fmt.Sprintf("Removed: %s", escapePathVar(intPath)))
+}
+
+func printUnstaged(intPath string) {
+	printDryRun(newEvent(output.ActionUnstaged, intPath, ""), "unstage: %s", escapePathVar(intPath))
+}
+
+func newEvent(action, intPath, extPath string) output.Event {
+	return output.Event{
+		Action:     action,
+		IntPath:    intPath,
+		ExtPath:    extPath,
+		Repository: repositoryName(intPath),
+		DryRun:     DryRun,
+	}
+}
+
+// repositoryName returns the name of the repository which contains intPath,
+// or an empty string if it is not within gog's data directory
+func repositoryName(intPath string) string {
+	rel, err := filepath.Rel(repository.BaseDir, intPath)
+	if intPath == "" || err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return strings.SplitN(rel, string(filepath.Separator), 2)[0]
 }
 
-func escapeHomeVar(p string) string {
-	return strings.Replace(p, "$HOME", "\\$HOME", 1)
+// escapePathVar escapes the path variable in an internal path, e.g. "$HOME",
+// so that the path can be pasted into a shell
+func escapePathVar(p string) string {
+	return strings.Replace(p, "/$", "/\\$", 1)
 }
@@ -0,0 +1,121 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andornaut/gog/internal/git"
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// StaleLink is a symbolic link to a file which has been deleted from a repository
+type StaleLink struct {
+	RepoPath string
+	IntPath  string
+	ExtPath  string
+	// BackupPath is the path of a .gog backup that could be restored, or empty if there is none
+	BackupPath string
+}
+
+// StaleLinks returns the symbolic links to files which have been deleted from
+// the given repository since they were recorded in its state, or in its
+// working tree
+func StaleLinks(repoPath string) ([]StaleLink, error) {
+	deletedPaths, err := deletedPaths(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	recordedPaths, err := recordedPaths(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range recordedPaths {
+		if !slices.Contains(deletedPaths, p) {
+			deletedPaths = append(deletedPaths, p)
+		}
+	}
+
+	var staleLinks []StaleLink
+	for _, intPath := range deletedPaths {
+		if _, err := os.Lstat(intPath); !os.IsNotExist(err) {
+			// The file has been restored or re-added since it was deleted
+			continue
+		}
+		extPath := repository.ToExternalPath(repoPath, intPath)
+		if linkTarget, err := readlink(extPath); err != nil || linkTarget != intPath {
+			continue
+		}
+
+		s := StaleLink{RepoPath: repoPath, IntPath: intPath, ExtPath: extPath}
+		if _, err := os.Lstat(backupPath(extPath)); err == nil {
+			s.BackupPath = backupPath(extPath)
+		}
+		staleLinks = append(staleLinks, s)
+	}
+	return staleLinks, nil
+}
+
+// Prune removes a stale link, and then restores its backup if restore is true
+// and there is one
+func Prune(s StaleLink, restore bool) error {
+	if interrupted.Load() {
+		return ErrInterrupted
+	}
+	if restore && s.BackupPath != "" {
+		printPruned(s.IntPath, s.ExtPath)
+		if err := restoreBackup(s.BackupPath, s.ExtPath); err != nil {
+			return err
+		}
+		return forget(s.RepoPath, s.ExtPath)
+	}
+	if err := removeLink(s.ExtPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", s.ExtPath, err)
+	}
+	printPruned(s.IntPath, s.ExtPath)
+	return forget(s.RepoPath, s.ExtPath)
+}
+
+// recordedPaths returns the internal paths of the symbolic links in the repository's state
+func recordedPaths(repoPath string) ([]string, error) {
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range s.List() {
+		if e.Mode == repository.ModeSymlink {
+			paths = append(paths, e.IntPath)
+		}
+	}
+	return paths, nil
+}
+
+// deletedPaths returns the absolute paths of files which were deleted from the
+// repository's working tree or index, but not committed. Files that were
+// deleted by commits are found through the state instead, because walking the
+// repository's whole history would be slow.
+func deletedPaths(repoPath string) ([]string, error) {
+	arguments := []string{"-c", "core.quotepath=off", "ls-files", "--deleted"}
+	if git.HasCommits(repoPath) {
+		arguments = []string{"-c", "core.quotepath=off", "diff", "--diff-filter=D", "--name-only", "HEAD"}
+	}
+	out, err := git.Output(repoPath, arguments...)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, p := range strings.Split(out, "\n") {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		paths = append(paths, filepath.Join(repoPath, p))
+	}
+	return paths, nil
+}
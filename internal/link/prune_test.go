@@ -0,0 +1,109 @@
+package link
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestPruneRemovesLinksToDeletedFiles verifies stale links are found and removed
+func TestPruneRemovesLinksToDeletedFiles(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	// Set up home directory for testing
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	// Create and link a test file in the repo
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err = os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err = os.WriteFile(intPath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err = File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	cmd := exec.Command("git", "commit", "-qm", "Add .bashrc")
+	cmd.Dir = repoPath
+	if err = cmd.Run(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	// Delete the file from the repository
+	cmd = exec.Command("git", "rm", "-q", intPath)
+	cmd.Dir = repoPath
+	if err = cmd.Run(); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	staleLinks, err := StaleLinks(repoPath)
+	if err != nil {
+		t.Fatalf("StaleLinks() failed: %v", err)
+	}
+	if len(staleLinks) != 1 {
+		t.Fatalf("StaleLinks() returned %d links, want 1", len(staleLinks))
+	}
+
+	extPath := filepath.Join(testHome, ".bashrc")
+	if staleLinks[0].ExtPath != extPath {
+		t.Errorf("StaleLinks()[0].ExtPath = %q, want %q", staleLinks[0].ExtPath, extPath)
+	}
+
+	if err = Prune(staleLinks[0], true); err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if _, err := os.Lstat(extPath); !os.IsNotExist(err) {
+		t.Error("Stale link should be removed")
+	}
+}
+
+// TestStaleLinksFindsFilesDeletedByCommits verifies links to files which were
+// deleted by a commit are found through the state
+func TestStaleLinksFindsFilesDeletedByCommits(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+	testHome := t.TempDir()
+	defer repository.SetHomeDirForTest(repository.SetHomeDirForTest(testHome))
+
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	for _, args := range [][]string{
+		{"commit", "-qm", "Add .bashrc"},
+		{"rm", "-q", intPath},
+		{"commit", "-qm", "Delete .bashrc"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	staleLinks, err := StaleLinks(repoPath)
+	if err != nil {
+		t.Fatalf("StaleLinks() failed: %v", err)
+	}
+	if len(staleLinks) != 1 || staleLinks[0].IntPath != intPath {
+		t.Errorf("StaleLinks() = %v, want the link to %q", staleLinks, intPath)
+	}
+}
@@ -0,0 +1,160 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// Modes of the state entries of files which are generated from repository files
+const (
+	modeEncrypted = "encrypted"
+	modeTemplate  = "template"
+)
+
+// recordSymlink records that extPath is a symbolic link to intPath
+func recordSymlink(repoPath, intPath, extPath string) error {
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return err
+	}
+	return record(s, repository.ModeSymlink, intPath, extPath, "")
+}
+
+// record records that the files at intPath and extPath have the content with the given hash
+func record(s *state.State, mode, intPath, extPath, hash string) error {
+	if DryRun {
+		return nil
+	}
+	e := state.Entry{ExtPath: extPath, IntPath: intPath, Mode: mode, Hash: hash}
+	if current := s.Get(extPath); current != nil {
+		e.BackupPath = current.BackupPath
+		e.Time = current.Time
+		if *current == e {
+			return nil
+		}
+	}
+	e.Time = time.Now()
+	s.Set(e)
+	return nil
+}
+
+// recordBackup records the backup of the file that was at extPath, before it is replaced
+func recordBackup(repoPath, intPath, extPath, backupPath string) error {
+	if DryRun {
+		return nil
+	}
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return err
+	}
+	e := state.Entry{ExtPath: extPath, IntPath: intPath, Mode: mode(repoPath, intPath)}
+	if current := s.Get(extPath); current != nil {
+		e = *current
+	}
+	e.BackupPath = backupPath
+	e.Time = time.Now()
+	s.Set(e)
+	return nil
+}
+
+// forgetBackup records that the backup of the file at extPath was restored or deleted
+func forgetBackup(repoPath, extPath string) error {
+	if DryRun {
+		return nil
+	}
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return err
+	}
+	e := s.Get(extPath)
+	if e == nil || e.BackupPath == "" {
+		return nil
+	}
+	updated := *e
+	updated.BackupPath = ""
+	updated.Time = time.Now()
+	s.Set(updated)
+	return nil
+}
+
+// isRecordedLink returns true if gog created the symbolic link at extPath to
+// linkTarget, which is within any repository
+func isRecordedLink(extPath, linkTarget string) bool {
+	name := repositoryName(linkTarget)
+	if name == "" {
+		return false
+	}
+	s, err := state.Load(filepath.Join(repository.BaseDir, name))
+	if err != nil {
+		return false
+	}
+	e := s.Get(extPath)
+	return e != nil && e.Mode == repository.ModeSymlink && e.IntPath == linkTarget
+}
+
+// isCopy returns true if the external path of a repository file is a regular
+// file, which is a rendered template, a decrypted file or a copy, rather than a link
+func isCopy(repoPath, intPath, extPath string) bool {
+	switch mode(repoPath, intPath) {
+	case repository.ModeCopy:
+		return true
+	case repository.ModeHardlink:
+		// The file was copied if it is on a different device than the repository
+		s, err := state.Load(repoPath)
+		return err == nil && s.Get(extPath) != nil && s.Get(extPath).Mode == repository.ModeCopy
+	}
+	return repository.IsTemplate(intPath) || repository.IsEncrypted(intPath)
+}
+
+// isUnmodified returns true if the regular file at extPath was written by gog,
+// and has not changed since
+func isUnmodified(repoPath, extPath string) bool {
+	s, err := state.Load(repoPath)
+	if err != nil || s.Get(extPath) == nil {
+		return false
+	}
+	hash, err := state.Hash(extPath)
+	return err == nil && hash == s.Get(extPath).Hash
+}
+
+// isReplaced returns true if the link that gog created at extPath was replaced
+// by a regular file, e.g. by an editor that saves files by renaming a new file
+// over the old one
+func isReplaced(repoPath, intPath, extPath string) bool {
+	extFileInfo, err := os.Lstat(extPath)
+	if err != nil || !extFileInfo.Mode().IsRegular() || isSameFile(extPath, intPath) {
+		return false
+	}
+	s, err := state.Load(repoPath)
+	if err != nil || s.Get(extPath) == nil {
+		return false
+	}
+	switch s.Get(extPath).Mode {
+	case repository.ModeSymlink:
+		return true
+	case repository.ModeHardlink:
+		// Otherwise, the hard link was broken when git replaced the repository file
+		return !isUnmodified(repoPath, extPath)
+	}
+	return false
+}
+
+// forget removes the record of a file
+func forget(repoPath, extPath string) error {
+	if DryRun {
+		return nil
+	}
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return err
+	}
+	if s.Get(extPath) == nil {
+		return nil
+	}
+	s.Delete(extPath)
+	return nil
+}
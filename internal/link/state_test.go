@@ -0,0 +1,206 @@
+package link
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// TestFileRecordsLinksAndBackups verifies the state records links with their backups until they are restored
+func TestFileRecordsLinksAndBackups(t *testing.T) {
+	originalBackupDisabled := backupDisabled
+	backupDisabled = false
+	defer func() { backupDisabled = originalBackupDisabled }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("new content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	extPath := filepath.Join(testHome, ".bashrc")
+	if err := os.WriteFile(extPath, []byte("existing content"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	s, err := state.Load(repoPath)
+	if err != nil {
+		t.Fatalf("state.Load() failed: %v", err)
+	}
+	e := s.Get(extPath)
+	if e == nil {
+		t.Fatal("Link was not recorded")
+	}
+	if e.IntPath != intPath || e.Mode != repository.ModeSymlink || e.BackupPath != backupPath(extPath) || e.Time.IsZero() {
+		t.Errorf("Entry = %+v, want a symlink to %s with backup %s", e, intPath, backupPath(extPath))
+	}
+
+	if err := RestoreFile(repoPath, intPath); err != nil {
+		t.Fatalf("RestoreFile() failed: %v", err)
+	}
+	if s.Get(extPath) != nil {
+		t.Error("Restored file should no longer be recorded")
+	}
+}
+
+// TestFileBacksUpUnrecordedLinksIntoRepositories verifies only links that gog
+// created for lower or equal priority repositories are replaced without a backup
+func TestFileBacksUpUnrecordedLinksIntoRepositories(t *testing.T) {
+	originalBackupDisabled := backupDisabled
+	backupDisabled = false
+	defer func() { backupDisabled = originalBackupDisabled }()
+
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	otherRepoPath := filepath.Join(repository.BaseDir, "other")
+	if err := os.MkdirAll(otherRepoPath, 0755); err != nil {
+		t.Fatalf("Failed to create repo dir: %v", err)
+	}
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = otherRepoPath
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to initialize git repo: %v", err)
+	}
+
+	var intPaths []string
+	for _, p := range []string{repoPath, otherRepoPath} {
+		intPath := filepath.Join(p, "$HOME", ".bashrc")
+		if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(intPath, []byte(p), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		intPaths = append(intPaths, intPath)
+	}
+	intPath, otherIntPath := intPaths[0], intPaths[1]
+	extPath := filepath.Join(testHome, ".bashrc")
+
+	// A link that was created by hand is backed up
+	if err := os.Symlink(otherIntPath, extPath); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if linkDest, _ := os.Readlink(backupPath(extPath)); linkDest != otherIntPath {
+		t.Errorf("Backup links to %q, want %q", linkDest, otherIntPath)
+	}
+	if err := os.Remove(backupPath(extPath)); err != nil {
+		t.Fatalf("Failed to remove backup: %v", err)
+	}
+
+	// A link that gog created for the other repository is replaced
+	if err := os.Remove(extPath); err != nil {
+		t.Fatalf("Failed to remove link: %v", err)
+	}
+	if err := File(otherRepoPath, otherIntPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if _, err := os.Lstat(backupPath(extPath)); !os.IsNotExist(err) {
+		t.Error("Link recorded for another repository should not be backed up")
+	}
+	if linkDest, _ := os.Readlink(extPath); linkDest != intPath {
+		t.Errorf("Symlink points to %q, want %q", linkDest, intPath)
+	}
+	otherState, err := state.Load(otherRepoPath)
+	if err != nil {
+		t.Fatalf("state.Load() failed: %v", err)
+	}
+	if otherState.Get(extPath) != nil {
+		t.Error("Replaced link should no longer be recorded for the other repository")
+	}
+
+	// A link that gog created for a higher priority repository is kept
+	t.Setenv("GOG_REPOSITORY_PRIORITY", "other")
+	if err := os.Remove(extPath); err != nil {
+		t.Fatalf("Failed to remove link: %v", err)
+	}
+	if err := File(otherRepoPath, otherIntPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if linkDest, _ := os.Readlink(extPath); linkDest != otherIntPath {
+		t.Errorf("Symlink points to %q, want %q", linkDest, otherIntPath)
+	}
+	if otherState.Get(extPath) == nil {
+		t.Error("Link should remain recorded for the higher priority repository")
+	}
+}
+
+// TestStaleLinksIncludesRecordedLinks verifies links are stale when their file
+// is deleted, even if git never tracked the deletion
+func TestStaleLinksIncludesRecordedLinks(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	// Unstage and delete the file, so that git has no record of it
+	cmd := exec.Command("git", "rm", "-q", "--cached", intPath)
+	cmd.Dir = repoPath
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to unstage file: %v", err)
+	}
+	if err := os.Remove(intPath); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	staleLinks, err := StaleLinks(repoPath)
+	if err != nil {
+		t.Fatalf("StaleLinks() failed: %v", err)
+	}
+	if len(staleLinks) != 1 {
+		t.Fatalf("StaleLinks() returned %d links, want 1", len(staleLinks))
+	}
+	if err := Prune(staleLinks[0], false); err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+
+	s, err := state.Load(repoPath)
+	if err != nil {
+		t.Fatalf("state.Load() failed: %v", err)
+	}
+	if s.Get(staleLinks[0].ExtPath) != nil {
+		t.Error("Pruned link should no longer be recorded")
+	}
+}
@@ -0,0 +1,226 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/secret"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// State describes how a repository file relates to its external path
+type State string
+
+const (
+	// StateLinked means the external path is a symlink to the repository file
+	StateLinked State = "linked"
+	// StateMissing means nothing exists at the external path
+	StateMissing State = "missing"
+	// StateConflict means a file or directory that gog did not create exists at the external path
+	StateConflict State = "conflict"
+	// StateLinkedElsewhere means the external path is a symlink into a different repository
+	StateLinkedElsewhere State = "linked-elsewhere"
+	// StateBroken means the external path is a symlink whose target does not exist
+	StateBroken State = "broken"
+	// StateIgnored means the repository file is never linked
+	StateIgnored State = "ignored"
+	// StateOtherVariant means that a different variant of the repository file is linked on this machine
+	StateOtherVariant State = "other-variant"
+	// StateRendered means the external path contains the rendered output of a template
+	StateRendered State = "rendered"
+	// StateDecrypted means the external path contains the decrypted content of an encrypted file
+	StateDecrypted State = "decrypted"
+	// StateDrifted means the external path differs from the rendered output of a template, or the decrypted content of an encrypted file
+	StateDrifted State = "drifted"
+	// StateCopied means the external path is an identical copy of the repository file
+	StateCopied State = "copied"
+	// StateModified means the copy at the external path changed since it was last synced
+	StateModified State = "modified"
+	// StateReplaced means a link that gog created was replaced by a regular file, e.g. by an editor
+	StateReplaced State = "replaced"
+	// StateOutdated means the repository file changed since it was last copied or hard linked
+	StateOutdated State = "outdated"
+)
+
+// FileStatus is the link state of a single repository file
+type FileStatus struct {
+	IntPath string
+	ExtPath string
+	State   State
+	// Target is the destination of the symlink at ExtPath, if any
+	Target string
+	// Drift describes how the repository file's permissions and ownership
+	// differ from those that were recorded when it was added, if they do
+	Drift string
+}
+
+// InSync returns true if no action is required to link the file
+func (s FileStatus) InSync() bool {
+	if s.Drift != "" {
+		return false
+	}
+	switch s.State {
+	case StateLinked, StateIgnored, StateOtherVariant, StateRendered, StateDecrypted, StateCopied:
+		return true
+	}
+	return false
+}
+
+// Status walks a repository directory the same way as `Dir` and returns the
+// link state of each file without modifying the filesystem
+func Status(repoPath, intPath string) ([]FileStatus, error) {
+	var statuses []FileStatus
+	err := walk(repoPath, intPath, func(p string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		statuses = append(statuses, FileState(repoPath, p))
+		return nil
+	})
+	return statuses, err
+}
+
+// FileState returns the link state of a single repository file
+func FileState(repoPath, intPath string) FileStatus {
+	s := FileStatus{
+		IntPath: intPath,
+		ExtPath: repository.ToExternalPath(repoPath, intPath),
+	}
+	if isIgnored(repoPath, intPath, false) {
+		s.State = StateIgnored
+		return s
+	}
+	if !isSelectedVariant(intPath) {
+		s.State = StateOtherVariant
+		return s
+	}
+	s.Drift = metadataDrift(repoPath, intPath, s.ExtPath)
+
+	extFileInfo, err := os.Lstat(s.ExtPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.State = StateMissing
+		} else {
+			s.State = StateConflict
+		}
+		return s
+	}
+
+	if repository.IsTemplate(intPath) && extFileInfo.Mode().IsRegular() {
+		s.State = StateDrifted
+		if content, err := Render(intPath); err == nil && isRendered(s.ExtPath, content) {
+			s.State = StateRendered
+		}
+		return s
+	}
+	if repository.IsEncrypted(intPath) && extFileInfo.Mode().IsRegular() {
+		s.State = StateDrifted
+		if content, err := secret.DecryptFile(intPath); err == nil && isRendered(s.ExtPath, content) {
+			s.State = StateDecrypted
+		}
+		return s
+	}
+
+	if isCopy(repoPath, intPath, s.ExtPath) {
+		s.State = copyState(repoPath, intPath, s.ExtPath, extFileInfo)
+		return s
+	}
+	if mode(repoPath, intPath) == repository.ModeHardlink {
+		switch {
+		case !extFileInfo.Mode().IsRegular():
+			s.State = StateConflict
+		case isSameFile(s.ExtPath, intPath):
+			s.State = StateLinked
+		case isReplaced(repoPath, intPath, s.ExtPath):
+			s.State = StateReplaced
+		case isUnmodified(repoPath, s.ExtPath):
+			// The hard link was broken when the repository file was replaced, e.g. by `git pull`
+			s.State = StateOutdated
+		default:
+			s.State = StateConflict
+		}
+		return s
+	}
+
+	if extFileInfo.Mode()&os.ModeSymlink == 0 {
+		// The external path may still resolve to the repository file, e.g. when a parent directory is a symlink
+		switch {
+		case isSameFile(s.ExtPath, intPath):
+			s.State = StateLinked
+		case isReplaced(repoPath, intPath, s.ExtPath):
+			s.State = StateReplaced
+		default:
+			s.State = StateConflict
+		}
+		return s
+	}
+
+	s.Target, _ = readlink(s.ExtPath)
+	if s.Target == intPath {
+		s.State = StateLinked
+		return s
+	}
+
+	resolved, err := filepath.EvalSymlinks(s.ExtPath)
+	switch {
+	case err != nil:
+		s.State = StateBroken
+	case isSameFile(resolved, intPath):
+		s.State = StateLinked
+	case isWithinBaseDir(s.Target) || isWithinBaseDir(resolved):
+		s.State = StateLinkedElsewhere
+	default:
+		s.State = StateConflict
+	}
+	return s
+}
+
+func copyState(repoPath, intPath, extPath string, extFileInfo os.FileInfo) State {
+	if !extFileInfo.Mode().IsRegular() {
+		return StateConflict
+	}
+	intHash, err := state.Hash(intPath)
+	if err != nil {
+		return StateConflict
+	}
+	extHash, err := state.Hash(extPath)
+	if err != nil {
+		return StateConflict
+	}
+	if intHash == extHash {
+		return StateCopied
+	}
+
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return StateConflict
+	}
+	switch entry := s.Get(extPath); {
+	case entry == nil:
+		return StateConflict
+	case entry.Hash == intHash:
+		return StateModified
+	case entry.Hash == extHash:
+		return StateOutdated
+	}
+	// Both copies changed
+	return StateConflict
+}
+
+func isWithinBaseDir(p string) bool {
+	return strings.HasPrefix(p, repository.BaseDir+string(filepath.Separator))
+}
+
+func isSameFile(a, b string) bool {
+	aFileInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bFileInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aFileInfo, bFileInfo)
+}
@@ -0,0 +1,122 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestFileStateClassifiesExternalPaths verifies each link state is detected
+func TestFileStateClassifiesExternalPaths(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	// Set up home directory for testing
+	testHome, err := os.MkdirTemp("", "gog-home-*")
+	if err != nil {
+		t.Fatalf("Failed to create test home: %v", err)
+	}
+	defer os.RemoveAll(testHome)
+
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	// Treat the parent of the test repository as gog's data directory
+	originalBaseDir := repository.BaseDir
+	repository.BaseDir = filepath.Dir(repoPath)
+	defer func() { repository.BaseDir = originalBaseDir }()
+
+	otherRepoFile := filepath.Join(repository.BaseDir, "other", "file")
+	if err = os.MkdirAll(filepath.Dir(otherRepoFile), 0755); err != nil {
+		t.Fatalf("Failed to create other repo: %v", err)
+	}
+	if err = os.WriteFile(otherRepoFile, []byte("other"), 0644); err != nil {
+		t.Fatalf("Failed to create other repo file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(intPath, extPath string) error
+		expected State
+	}{
+		{
+			name:     "missing",
+			setup:    func(_, _ string) error { return nil },
+			expected: StateMissing,
+		},
+		{
+			name:     "linked",
+			setup:    os.Symlink,
+			expected: StateLinked,
+		},
+		{
+			name: "conflict",
+			setup: func(_, extPath string) error {
+				return os.WriteFile(extPath, []byte("local"), 0644)
+			},
+			expected: StateConflict,
+		},
+		{
+			name: "linked-elsewhere",
+			setup: func(_, extPath string) error {
+				return os.Symlink(otherRepoFile, extPath)
+			},
+			expected: StateLinkedElsewhere,
+		},
+		{
+			name: "broken",
+			setup: func(_, extPath string) error {
+				return os.Symlink(filepath.Join(testHome, "nonexistent"), extPath)
+			},
+			expected: StateBroken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intPath := filepath.Join(repoPath, "$HOME", tt.name)
+			if mkdirErr := os.MkdirAll(filepath.Dir(intPath), 0755); mkdirErr != nil {
+				t.Fatalf("Failed to create dir: %v", mkdirErr)
+			}
+			if writeErr := os.WriteFile(intPath, []byte("test content"), 0644); writeErr != nil {
+				t.Fatalf("Failed to create test file: %v", writeErr)
+			}
+			extPath := repository.ToExternalPath(repoPath, intPath)
+			if setupErr := tt.setup(intPath, extPath); setupErr != nil {
+				t.Fatalf("Failed to set up external path: %v", setupErr)
+			}
+
+			s := FileState(repoPath, intPath)
+			if s.State != tt.expected {
+				t.Errorf("FileState(%q).State = %q, want %q", intPath, s.State, tt.expected)
+			}
+		})
+	}
+}
+
+// TestStatusReportsIgnoredFiles verifies the walk includes ignored files
+func TestStatusReportsIgnoredFiles(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	intPath := filepath.Join(repoPath, "README.md")
+	if err := os.WriteFile(intPath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	statuses, err := Status(repoPath, repoPath)
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("Status() returned %d files, want 1", len(statuses))
+	}
+	if statuses[0].State != StateIgnored {
+		t.Errorf("README.md state = %q, want %q", statuses[0].State, StateIgnored)
+	}
+	if !statuses[0].InSync() {
+		t.Error("Ignored files should be in sync")
+	}
+}
@@ -0,0 +1,155 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// copyFile copies a repository file to its external path, instead of linking
+// it, and records its content so that later changes to either copy can be detected
+func copyFile(repoPath, intPath, extPath string) error {
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	intHash, err := state.Hash(intPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	entry := s.Get(extPath)
+
+	extFileInfo, err := os.Lstat(extPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fail(intPath, extPath, err)
+	case extFileInfo.IsDir():
+		return fail(intPath, extPath, fmt.Errorf("cannot copy file: %s exists and is a directory (remove the directory or use a different location)", extPath))
+	case extFileInfo.Mode().IsRegular():
+		extHash, err := state.Hash(extPath)
+		if err != nil {
+			return fail(intPath, extPath, err)
+		}
+		switch {
+		case extHash == intHash:
+			// Already copied
+			if err := applyOwner(repoPath, intPath, extPath); err != nil {
+				return fail(intPath, extPath, err)
+			}
+			if err := record(s, repository.ModeCopy, intPath, extPath, intHash); err != nil {
+				return fail(intPath, extPath, err)
+			}
+			return addToGit(repoPath, intPath)
+		case entry == nil:
+			// The file was not created by gog
+			if err := clearExtPath(repoPath, intPath, extPath); err != nil {
+				return fail(intPath, extPath, err)
+			}
+		case entry.Hash == intHash:
+			printModified(intPath, extPath)
+			results.Skipped++
+			return nil
+		case entry.Hash != extHash:
+			return fail(intPath, extPath, errChangedOnBothSides(intPath, extPath))
+		}
+		// Otherwise, only the repository file changed, so the copy can be replaced
+	default:
+		if err := clearExtPath(repoPath, intPath, extPath); err != nil {
+			return fail(intPath, extPath, err)
+		}
+	}
+
+	// The repository file changes if the conflicting file was adopted
+	if intHash, err = state.Hash(intPath); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	if err := writeCopy(intPath, extPath); err != nil {
+		return fail(intPath, extPath, fmt.Errorf("failed to copy %s to %s: %w", intPath, extPath, err))
+	}
+	printWroteCopy(intPath, extPath)
+	if err := applyOwner(repoPath, intPath, extPath); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	if err := record(s, repository.ModeCopy, intPath, extPath, intHash); err != nil {
+		return fail(intPath, extPath, err)
+	}
+	return addToGit(repoPath, intPath)
+}
+
+// Sync syncs the copied files at the given paths
+func Sync(repoPath string, paths []string) error {
+	return syncLinks(repoPath, paths, SyncDir, SyncFile)
+}
+
+// SyncDir recursively syncs the copied files in a repository directory
+func SyncDir(repoPath, intPath string) error {
+	return walk(repoPath, intPath, func(p string, info os.FileInfo) error {
+		if info.IsDir() {
+			if isIgnored(repoPath, p, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return SyncFile(repoPath, p)
+	})
+}
+
+// SyncFile copies local changes to a copied file back into the repository, or
+// repository changes out to the copy. A file which changed on both sides since
+// it was last synced is reported as failed. Files which are not copied are skipped.
+func SyncFile(repoPath, intPath string) error {
+	extPath := repository.ToExternalPath(repoPath, intPath)
+	if isIgnored(repoPath, intPath, false) || !isSelectedVariant(intPath) || repository.IsTemplate(intPath) || repository.IsEncrypted(intPath) || !isCopy(repoPath, intPath, extPath) {
+		return nil
+	}
+
+	s, err := state.Load(repoPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	extFileInfo, err := os.Lstat(extPath)
+	if err != nil || !extFileInfo.Mode().IsRegular() {
+		// Not copied yet
+		return copyFile(repoPath, intPath, extPath)
+	}
+
+	intHash, err := state.Hash(intPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	extHash, err := state.Hash(extPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	entry := s.Get(extPath)
+	switch {
+	case extHash == intHash:
+		if err := record(s, repository.ModeCopy, intPath, extPath, intHash); err != nil {
+			return fail(intPath, extPath, err)
+		}
+		results.Linked++
+		return nil
+	case entry != nil && entry.Hash == intHash:
+		// Only the copy changed
+		if err := writeCopy(extPath, intPath); err != nil {
+			return fail(intPath, extPath, fmt.Errorf("failed to copy %s to %s: %w", extPath, intPath, err))
+		}
+		printSynced(intPath, extPath)
+		if err := record(s, repository.ModeCopy, intPath, extPath, extHash); err != nil {
+			return fail(intPath, extPath, err)
+		}
+		return addToGit(repoPath, intPath)
+	case entry != nil && entry.Hash == extHash:
+		// Only the repository file changed
+		return copyFile(repoPath, intPath, extPath)
+	}
+	return fail(intPath, extPath, errChangedOnBothSides(intPath, extPath))
+}
+
+func errChangedOnBothSides(intPath, extPath string) error {
+	return fmt.Errorf("both %s and %s changed since they were last synced (make them identical, and then run `gog sync`)", extPath, intPath)
+}
@@ -0,0 +1,100 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestCopyModeDetectsChangesOnEitherSide verifies copies are synced in the direction of the change
+func TestCopyModeDetectsChangesOnEitherSide(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	originalResults := results
+	results = Errors{}
+	defer func() { results = originalResults }()
+
+	config := "[modes]\n\"$HOME/.config/app\" = \"copy\"\n"
+	if err := os.WriteFile(filepath.Join(repoPath, repository.ConfigFileName), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	intPath := filepath.Join(repoPath, "$HOME", ".config", "app", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	extPath := filepath.Join(testHome, ".config", "app", "settings.json")
+
+	assertContent := func(p, expected string) {
+		t.Helper()
+		if content, _ := os.ReadFile(p); string(content) != expected {
+			t.Errorf("%s content = %q, want %q", p, content, expected)
+		}
+	}
+	assertState := func(expected State) {
+		t.Helper()
+		if s := FileState(repoPath, intPath); s.State != expected {
+			t.Errorf("State = %q, want %q", s.State, expected)
+		}
+	}
+
+	if err := Dir(repoPath, repoPath); err != nil {
+		t.Fatalf("Dir() failed: %v", err)
+	}
+	if isSymlink(extPath) {
+		t.Fatal("Copied file should not be a symlink")
+	}
+	assertContent(extPath, "v1")
+	assertState(StateCopied)
+
+	// A local edit is not overwritten by apply, and is synced into the repository
+	if err := os.WriteFile(extPath, []byte("v2 local"), 0644); err != nil {
+		t.Fatalf("Failed to edit copy: %v", err)
+	}
+	assertState(StateModified)
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	assertContent(extPath, "v2 local")
+	if err := SyncDir(repoPath, repoPath); err != nil {
+		t.Fatalf("SyncDir() failed: %v", err)
+	}
+	assertContent(intPath, "v2 local")
+	assertState(StateCopied)
+
+	// A repository change is copied by apply
+	if err := os.WriteFile(intPath, []byte("v3 repository"), 0644); err != nil {
+		t.Fatalf("Failed to edit repository file: %v", err)
+	}
+	assertState(StateOutdated)
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	assertContent(extPath, "v3 repository")
+
+	// Changes on both sides are a conflict
+	if err := os.WriteFile(intPath, []byte("v4 repository"), 0644); err != nil {
+		t.Fatalf("Failed to edit repository file: %v", err)
+	}
+	if err := os.WriteFile(extPath, []byte("v4 local"), 0644); err != nil {
+		t.Fatalf("Failed to edit copy: %v", err)
+	}
+	assertState(StateConflict)
+	if err := SyncFile(repoPath, intPath); err != nil {
+		t.Fatalf("SyncFile() failed: %v", err)
+	}
+	if _, err := Results(); err == nil {
+		t.Error("Results() should report the conflict")
+	}
+	assertContent(intPath, "v4 repository")
+	assertContent(extPath, "v4 local")
+}
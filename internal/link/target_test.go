@@ -0,0 +1,62 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestFileLinksRelativelyWithinTargetRoot verifies files that are applied to
+// another root are linked with relative links, which are valid inside of it
+func TestFileLinksRelativelyWithinTargetRoot(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	root := filepath.Dir(repoPath)
+	defer repository.SetTargetRootForTest(repository.SetTargetRootForTest(root))
+	defer repository.SetHomeDirForTest(repository.SetHomeDirForTest("/home/dev"))
+
+	intPath := filepath.Join(repoPath, "$HOME", ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("bashrc"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	extPath := filepath.Join(root, "home", "dev", ".bashrc")
+	if got := repository.ToExternalPath(repoPath, intPath); got != extPath {
+		t.Fatalf("ToExternalPath() = %q, want %q", got, extPath)
+	}
+	if got := repository.ToInternalPath(repoPath, extPath); got != intPath {
+		t.Fatalf("ToInternalPath() = %q, want %q", got, intPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(extPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	linkTarget, err := os.Readlink(extPath)
+	if err != nil {
+		t.Fatalf("Readlink() failed: %v", err)
+	}
+	want := filepath.Join("..", "..", filepath.Base(repoPath), "$HOME", ".bashrc")
+	if linkTarget != want {
+		t.Errorf("Link target = %q, want %q", linkTarget, want)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateLinked {
+		t.Errorf("FileState() = %q, want %q", s.State, StateLinked)
+	}
+
+	// Linking again leaves the link in place
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if _, err := os.Lstat(backupPath(extPath)); err == nil {
+		t.Error("The link was backed up, want it to be left in place")
+	}
+}
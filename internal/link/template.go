@@ -0,0 +1,154 @@
+package link
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/andornaut/gog/internal/repository"
+	"github.com/andornaut/gog/internal/state"
+)
+
+// TemplateData is the data with which templates are rendered
+type TemplateData struct {
+	Hostname string
+	OS       string
+	Arch     string
+	User     string
+	Home     string
+	// Vars are read from the local values file ($GOG_VALUES_FILE)
+	Vars map[string]any
+}
+
+var templateData *TemplateData
+
+// Render renders a repository template
+func Render(intPath string) ([]byte, error) {
+	data, err := loadTemplateData()
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(filepath.Base(intPath)).Option("missingkey=error").ParseFiles(intPath)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// renderFile writes a rendered template to its external path, which is a
+// regular file that gog manages instead of a symbolic link
+func renderFile(repoPath, intPath, extPath string) error {
+	content, err := Render(intPath)
+	if err != nil {
+		return fail(intPath, extPath, fmt.Errorf("failed to render template: %w", err))
+	}
+	intFileInfo, err := os.Stat(intPath)
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	return generateFile(repoPath, intPath, extPath, content, intFileInfo.Mode().Perm(), modeTemplate, printRendered)
+}
+
+// generateFile writes content that was generated from a repository file, e.g.
+// by rendering or decrypting it, to its external path. Permissions which are not
+// in perm are removed from an existing file.
+func generateFile(repoPath, intPath, extPath string, content []byte, perm os.FileMode, mode string, print func(string, string)) error {
+	extFileInfo, err := os.Lstat(extPath)
+	switch {
+	case err == nil && extFileInfo.IsDir():
+		return fail(intPath, extPath, fmt.Errorf("cannot write file: %s exists and is a directory (remove the directory or use a different location)", extPath))
+	case err == nil && extFileInfo.Mode().IsRegular() && isRendered(extPath, content):
+		// Already written
+		if extFileInfo.Mode().Perm()&^perm != 0 {
+			if err := chmod(extPath, extFileInfo.Mode().Perm()&perm); err != nil {
+				return fail(intPath, extPath, err)
+			}
+		}
+		return generated(repoPath, intPath, extPath, content, mode)
+	case err == nil:
+		if err := clearExtPath(repoPath, intPath, extPath); err != nil {
+			return fail(intPath, extPath, err)
+		}
+	case !os.IsNotExist(err):
+		return fail(intPath, extPath, err)
+	}
+
+	if err := writeFile(extPath, content, perm); err != nil {
+		return fail(intPath, extPath, fmt.Errorf("failed to write %s: %w", extPath, err))
+	}
+	print(intPath, extPath)
+	return generated(repoPath, intPath, extPath, content, mode)
+}
+
+// generated gives a generated file its recorded owner, records it, and then
+// stages the repository file
+func generated(repoPath, intPath, extPath string, content []byte, mode string) error {
+	err := applyOwner(repoPath, intPath, extPath)
+	var s *state.State
+	if err == nil {
+		s, err = state.Load(repoPath)
+	}
+	if err == nil {
+		err = record(s, mode, intPath, extPath, state.HashBytes(content))
+	}
+	if err != nil {
+		return fail(intPath, extPath, err)
+	}
+	return addToGit(repoPath, intPath)
+}
+
+// isRendered returns true if the file at extPath contains the given rendered content
+func isRendered(extPath string, content []byte) bool {
+	current, err := os.ReadFile(extPath)
+	return err == nil && bytes.Equal(current, content)
+}
+
+func loadTemplateData() (*TemplateData, error) {
+	if templateData != nil {
+		return templateData, nil
+	}
+
+	data := &TemplateData{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		Home: repository.HomeDir(),
+		Vars: map[string]any{},
+	}
+	data.Hostname, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		data.User = u.Username
+	}
+
+	p := valuesFilePath()
+	if _, err := toml.DecodeFile(p, &data.Vars); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("invalid values file %s: %w", p, err)
+	}
+	templateData = data
+	return data, nil
+}
+
+// valuesFilePath returns the path of the local values file, which is not
+// stored in a repository, because its values differ between machines. It is
+// in the invoking user's home directory, like the identity file, even if files
+// are applied to another home directory.
+func valuesFilePath() string {
+	if p := os.Getenv("GOG_VALUES_FILE"); p != "" {
+		return p
+	}
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "gog", "values.toml")
+}
@@ -0,0 +1,134 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestFileRendersTemplate verifies templates are rendered to a regular file with their suffix removed
+func TestFileRendersTemplate(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testHome := t.TempDir()
+	originalHomeDir := repository.SetHomeDirForTest(testHome)
+	defer func() { repository.SetHomeDirForTest(originalHomeDir) }()
+
+	valuesFile := filepath.Join(t.TempDir(), "values.toml")
+	if err := os.WriteFile(valuesFile, []byte(`email = "alice@example.com"`), 0644); err != nil {
+		t.Fatalf("Failed to create values file: %v", err)
+	}
+	t.Setenv("GOG_VALUES_FILE", valuesFile)
+	templateData = nil
+	defer func() { templateData = nil }()
+
+	intPath := filepath.Join(repoPath, "$HOME", ".gitconfig"+repository.TemplateSuffix)
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, []byte("email={{ .Vars.email }} os={{ .OS }} home={{ .Home }}"), 0600); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	extPath := filepath.Join(testHome, ".gitconfig")
+	if p := repository.ToExternalPath(repoPath, intPath); p != extPath {
+		t.Fatalf("ToExternalPath() = %q, want %q", p, extPath)
+	}
+	if p := repository.ToInternalPath(repoPath, extPath); p != intPath {
+		t.Fatalf("ToInternalPath() = %q, want %q", p, intPath)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateMissing {
+		t.Errorf("State before rendering = %q, want %q", s.State, StateMissing)
+	}
+
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+
+	extFileInfo, err := os.Lstat(extPath)
+	if err != nil {
+		t.Fatalf("Rendered file does not exist: %v", err)
+	}
+	if !extFileInfo.Mode().IsRegular() {
+		t.Error("Rendered file should be a regular file")
+	}
+	if extFileInfo.Mode().Perm() != 0600 {
+		t.Errorf("Rendered file mode = %v, want 0600", extFileInfo.Mode().Perm())
+	}
+	content, _ := os.ReadFile(extPath)
+	expected := "email=alice@example.com os=" + runtime.GOOS + " home=" + testHome
+	if string(content) != expected {
+		t.Errorf("Rendered content = %q, want %q", content, expected)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateRendered {
+		t.Errorf("State after rendering = %q, want %q", s.State, StateRendered)
+	}
+
+	// Local edits are reported as drift, and then backed up when the template is rendered again
+	if err := os.WriteFile(extPath, []byte("edited"), 0600); err != nil {
+		t.Fatalf("Failed to edit rendered file: %v", err)
+	}
+	if s := FileState(repoPath, intPath); s.State != StateDrifted {
+		t.Errorf("State after editing = %q, want %q", s.State, StateDrifted)
+	}
+	if err := File(repoPath, intPath); err != nil {
+		t.Fatalf("File() failed: %v", err)
+	}
+	if content, _ := os.ReadFile(extPath); string(content) != expected {
+		t.Errorf("Rendered content = %q, want %q", content, expected)
+	}
+	if content, _ := os.ReadFile(backupPath(extPath)); string(content) != "edited" {
+		t.Errorf("Backup content = %q, want %q", content, "edited")
+	}
+}
+
+// TestRenderFailsForMissingValues verifies templates that refer to undefined variables are not rendered
+func TestRenderFailsForMissingValues(t *testing.T) {
+	t.Setenv("GOG_VALUES_FILE", filepath.Join(t.TempDir(), "nonexistent.toml"))
+	templateData = nil
+	defer func() { templateData = nil }()
+
+	intPath := filepath.Join(t.TempDir(), "config"+repository.TemplateSuffix)
+	if err := os.WriteFile(intPath, []byte("{{ .Vars.missing }}"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	if _, err := Render(intPath); err == nil {
+		t.Error("Render() should fail for undefined variables")
+	}
+}
+
+// TestTemplateDataReadsValuesFromInvokingUsersHome verifies the values file is
+// found in the user's home directory when files are applied to another home directory
+func TestTemplateDataReadsValuesFromInvokingUsersHome(t *testing.T) {
+	userHome := t.TempDir()
+	t.Setenv("HOME", userHome)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GOG_VALUES_FILE", "")
+	targetHome := t.TempDir()
+	defer repository.SetHomeDirForTest(repository.SetHomeDirForTest(targetHome))
+	templateData = nil
+	defer func() { templateData = nil }()
+
+	valuesFile := filepath.Join(userHome, ".config", "gog", "values.toml")
+	if err := os.MkdirAll(filepath.Dir(valuesFile), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(valuesFile, []byte(`email = "alice@example.com"`), 0644); err != nil {
+		t.Fatalf("Failed to create values file: %v", err)
+	}
+
+	data, err := loadTemplateData()
+	if err != nil {
+		t.Fatalf("loadTemplateData() failed: %v", err)
+	}
+	if data.Vars["email"] != "alice@example.com" {
+		t.Errorf("Vars[\"email\"] = %v, want \"alice@example.com\"", data.Vars["email"])
+	}
+	if data.Home != targetHome {
+		t.Errorf("Home = %q, want %q", data.Home, targetHome)
+	}
+}
@@ -3,9 +3,8 @@ package link
 import (
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/andornaut/gog/internal/copy"
-	"github.com/andornaut/gog/internal/git"
 	"github.com/andornaut/gog/internal/repository"
 )
 
@@ -17,6 +16,14 @@ func UnlinkDir(repoPath, intPath string) error {
 		}
 
 		if info.IsDir() {
+			extPath := repository.ToExternalPath(repoPath, p)
+			if linkTarget, _ := readlink(extPath); linkTarget == p {
+				// The directory is folded
+				if err := unlinkFolded(repoPath, p, extPath); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		return UnlinkFile(repoPath, p)
@@ -26,6 +33,14 @@ func UnlinkDir(repoPath, intPath string) error {
 // UnlinkFile replaces a symbolic link with the file that it linked to
 func UnlinkFile(repoPath, intPath string) error {
 	extPath := repository.ToExternalPath(repoPath, intPath)
+	if folded := foldedParent(extPath); folded != "" {
+		if linkTarget, _ := readlink(folded); strings.HasPrefix(intPath, linkTarget+"/") {
+			// Link the files of the folded directory individually, so that this file can be unlinked
+			if err := unfold(folded); err != nil {
+				return err
+			}
+		}
+	}
 
 	extFileInfo, err := os.Stat(extPath)
 	if err != nil {
@@ -36,17 +51,34 @@ func UnlinkFile(repoPath, intPath string) error {
 	if err != nil {
 		return err
 	}
-	if !os.SameFile(extFileInfo, intFileInfo) {
+	// Rendered templates and copies are regular files rather than links
+	copied := isCopy(repoPath, intPath, extPath)
+	if copied {
+		// Only update `extPath` if it is a file that was rendered or copied from `intPath`
+		if isSymlink(extPath) || !extFileInfo.Mode().IsRegular() {
+			return nil
+		}
+	} else if !os.SameFile(extFileInfo, intFileInfo) {
 		// Only update `extPath` if it is a symbolic link to `intPath`
 		return nil
 	}
 
-	if err := os.Remove(extPath); err != nil {
-		return err
+	_, backupErr := os.Lstat(backupPath(extPath))
+	switch {
+	case RestoreBackups && backupErr == nil:
+		if err := restoreBackup(backupPath(extPath), extPath); err != nil {
+			return err
+		}
+	case copied:
+		// The file is not linked to the repository, so it is left in place
+	default:
+		if err := replaceWithCopy(intPath, extPath); err != nil {
+			return err
+		}
 	}
-	if err := copy.File(intPath, extPath); err != nil {
+	if err := forget(repoPath, extPath); err != nil {
 		return err
 	}
 	printUnLinked(intPath)
-	return git.Run(repoPath, "rm", "-qf", intPath)
+	return removeFromGit(repoPath, intPath)
 }
@@ -0,0 +1,140 @@
+// Package output reports what gog does as human-readable text, or as JSON
+// events for other programs
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Format is an output format
+type Format string
+
+const (
+	// Text prints human-readable lines
+	Text Format = "text"
+	// JSON prints an array of all events when gog exits
+	JSON Format = "json"
+	// NDJSON prints one JSON event per line as soon as it happens
+	NDJSON Format = "ndjson"
+)
+
+// Actions which are reported by events
+const (
+	ActionAdded            = "added"
+	ActionAdopted          = "adopted"
+	ActionBackedUp         = "backed-up"
+	ActionBackup           = "backup"
+	ActionChanged          = "changed"
+	ActionCopied           = "copied"
+	ActionCreatedDirectory = "created-directory"
+	ActionDecrypted        = "decrypted"
+	ActionDiffered         = "differed"
+	ActionEncrypted        = "encrypted"
+	ActionError            = "error"
+	ActionHook             = "hook"
+	ActionIdentity         = "identity"
+	ActionImported         = "imported"
+	ActionLinked           = "linked"
+	ActionPruned           = "pruned"
+	ActionRemoved          = "removed"
+	ActionRendered         = "rendered"
+	ActionReplaced         = "replaced"
+	ActionRepository       = "repository"
+	ActionRestored         = "restored"
+	ActionSelected         = "selected"
+	ActionSkipped          = "skipped"
+	ActionStaged           = "staged"
+	ActionStatus           = "status"
+	ActionSynced           = "synced"
+	ActionUndone           = "undone"
+	ActionUnfolded         = "unfolded"
+	ActionUnlinked         = "unlinked"
+	ActionUnstaged         = "unstaged"
+)
+
+// Event describes something that gog did, or would do during a dry run
+type Event struct {
+	Action         string `json:"action"`
+	ExtPath        string `json:"external_path,omitempty"`
+	IntPath        string `json:"internal_path,omitempty"`
+	BackupPath     string `json:"backup_path,omitempty"`
+	Repository     string `json:"repository,omitempty"`
+	RepositoryPath string `json:"repository_path,omitempty"`
+	State          string `json:"state,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	Diff           string `json:"diff,omitempty"`
+	PublicKey      string `json:"public_key,omitempty"`
+	DryRun         bool   `json:"dry_run,omitempty"`
+}
+
+var (
+	format = Text
+	events = []Event{}
+)
+
+// SetFormat sets the output format, which must be one of "text", "json" or "ndjson"
+func SetFormat(s string) error {
+	switch f := Format(s); f {
+	case Text, JSON, NDJSON:
+		format = f
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q (must be one of: text, json, ndjson)", s)
+}
+
+// IsText returns true if human-readable text is printed
+func IsText() bool {
+	return format == Text
+}
+
+// Emit reports an event, which is printed as the given text line in text
+// format. Nothing is printed in text format if text is empty.
+func Emit(e Event, text string) {
+	emit(os.Stdout, e, text)
+}
+
+// EmitError reports an event, which is printed as the given text line to
+// standard error in text format
+func EmitError(e Event, text string) {
+	emit(os.Stderr, e, text)
+}
+
+// Println prints a line in text format only
+func Println(a ...any) {
+	if format == Text {
+		fmt.Println(a...)
+	}
+}
+
+// Close reports err, if it is not nil, and prints the events that were
+// collected in JSON format. In text format, err is reported by the caller.
+func Close(err error) {
+	if err != nil && format != Text {
+		Emit(Event{Action: ActionError, Reason: err.Error()}, "")
+	}
+	if format == JSON {
+		writeJSON(os.Stdout, events)
+	}
+}
+
+func emit(w io.Writer, e Event, text string) {
+	switch format {
+	case JSON:
+		events = append(events, e)
+	case NDJSON:
+		writeJSON(os.Stdout, e)
+	default:
+		if text != "" {
+			fmt.Fprintln(w, text)
+		}
+	}
+}
+
+func writeJSON(w io.Writer, v any) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR failed to encode JSON: %s\n", err)
+	}
+}
@@ -0,0 +1,40 @@
+package output
+
+import (
+	"testing"
+)
+
+// TestSetFormatRejectsUnknownFormats verifies only supported formats are accepted
+func TestSetFormatRejectsUnknownFormats(t *testing.T) {
+	defer func() { format = Text }()
+
+	for _, s := range []string{"text", "json", "ndjson"} {
+		if err := SetFormat(s); err != nil {
+			t.Errorf("SetFormat(%q) failed: %v", s, err)
+		}
+	}
+	if err := SetFormat("yaml"); err == nil {
+		t.Error("SetFormat should reject unknown formats")
+	}
+}
+
+// TestEmitCollectsEventsInJSONFormat verifies events are buffered until Close
+func TestEmitCollectsEventsInJSONFormat(t *testing.T) {
+	defer func() {
+		format = Text
+		events = []Event{}
+	}()
+
+	if err := SetFormat("json"); err != nil {
+		t.Fatalf("SetFormat() failed: %v", err)
+	}
+	Emit(Event{Action: ActionLinked, ExtPath: "/home/user/.bashrc"}, "ignored in JSON format")
+	EmitError(Event{Action: ActionError, Reason: "failed"}, "ignored in JSON format")
+
+	if len(events) != 2 {
+		t.Fatalf("Collected %d events, want 2", len(events))
+	}
+	if events[0].Action != ActionLinked || events[1].Action != ActionError {
+		t.Errorf("Collected events = %v, want linked and error events", events)
+	}
+}
@@ -0,0 +1,252 @@
+// Package privileged changes files which the current user is not permitted to
+// change, e.g. in /etc, by running only those operations as root through sudo
+// or pkexec. The rest of gog keeps running as the current user, so that it uses
+// the user's repositories and home directory.
+package privileged
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/andornaut/gog/internal/copy"
+)
+
+// Commands which run operations as root
+const (
+	CommandSudo   = "sudo"
+	CommandPkexec = "pkexec"
+)
+
+// HelperCommand is the name of the hidden gog subcommand which runs a single operation
+const HelperCommand = "privileged"
+
+// Operations which the helper runs
+const (
+	opChmod     = "chmod"
+	opChown     = "chown"
+	opCopy      = "copy"
+	opLink      = "link"
+	opMkdir     = "mkdir"
+	opMkdirAll  = "mkdir-all"
+	opRemove    = "remove"
+	opRemoveAll = "remove-all"
+	opRename    = "rename"
+	opSymlink   = "symlink"
+	opWriteFile = "write-file"
+)
+
+// DataDir is gog's data directory, which is passed to the helper
+var DataDir = ""
+
+var command = ""
+
+// SetCommand sets the command which runs operations as root, or disables
+// privilege escalation if it is empty
+func SetCommand(s string) error {
+	switch s {
+	case "", CommandSudo, CommandPkexec:
+		command = s
+		return nil
+	}
+	return fmt.Errorf("invalid privilege escalation command %q (must be one of: %s, %s)", s, CommandSudo, CommandPkexec)
+}
+
+// Enabled returns true if operations which the user is not permitted to run
+// are run as root
+func Enabled() bool {
+	return command != ""
+}
+
+// Chmod changes the permissions of the file at p
+func Chmod(p string, mode os.FileMode) error {
+	return retry(func() error { return os.Chmod(p, mode) }, nil, opChmod, p, strconv.FormatUint(uint64(mode), 10))
+}
+
+// Chown changes the owner and group of the file or link at p, or neither if they are -1
+func Chown(p string, uid, gid int) error {
+	return retry(func() error { return os.Lchown(p, uid, gid) }, nil, opChown, p, strconv.Itoa(uid), strconv.Itoa(gid))
+}
+
+// CopyFile replaces the file at dst with a copy of src, including its permissions
+func CopyFile(src, dst string) error {
+	return retry(func() error { return copy.File(src, dst) }, nil, opCopy, src, dst)
+}
+
+// Link creates a hard link at newname to oldname
+func Link(oldname, newname string) error {
+	return retry(func() error { return os.Link(oldname, newname) }, nil, opLink, oldname, newname)
+}
+
+// Mkdir creates the directory at p
+func Mkdir(p string, perm os.FileMode) error {
+	return retry(func() error { return os.Mkdir(p, perm) }, nil, opMkdir, p, strconv.FormatUint(uint64(perm), 10))
+}
+
+// MkdirAll creates the directory at p and its missing parents
+func MkdirAll(p string, perm os.FileMode) error {
+	return retry(func() error { return os.MkdirAll(p, perm) }, nil, opMkdirAll, p, strconv.FormatUint(uint64(perm), 10))
+}
+
+// Remove removes the file, link or empty directory at p
+func Remove(p string) error {
+	return retry(func() error { return os.Remove(p) }, nil, opRemove, p)
+}
+
+// RemoveAll removes p and everything below it
+func RemoveAll(p string) error {
+	return retry(func() error { return os.RemoveAll(p) }, nil, opRemoveAll, p)
+}
+
+// Rename renames oldPath to newPath
+func Rename(oldPath, newPath string) error {
+	return retry(func() error { return os.Rename(oldPath, newPath) }, nil, opRename, oldPath, newPath)
+}
+
+// Symlink creates a symbolic link at newname which points to oldname
+func Symlink(oldname, newname string) error {
+	return retry(func() error { return os.Symlink(oldname, newname) }, nil, opSymlink, oldname, newname)
+}
+
+// WriteFile writes content to the file at p, which is replaced if it exists
+func WriteFile(p string, content []byte, perm os.FileMode) error {
+	return retry(func() error { return os.WriteFile(p, content, perm) }, content, opWriteFile, p, strconv.FormatUint(uint64(perm), 10))
+}
+
+// retry calls f, and if it fails because the user is not permitted to change
+// the file, then it runs the same operation as root
+func retry(f func() error, stdin []byte, op string, args ...string) error {
+	err := f()
+	if err == nil || !errors.Is(err, fs.ErrPermission) || os.Geteuid() == 0 {
+		return err
+	}
+	if command == "" {
+		return fmt.Errorf("%w (use --escalate=sudo to change it as root)", err)
+	}
+	exe, exeErr := os.Executable()
+	if exeErr != nil {
+		return err
+	}
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		return err
+	}
+
+	// sudo and pkexec reset the environment, so the home and data directories are
+	// passed explicitly, which stops gog from creating one in root's home directory
+	argv := append([]string{"env", "HOME=" + home, "GOG_HOME=" + DataDir, exe, HelperCommand, op}, args...)
+	cmd := exec.Command(command, argv...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s %v failed: %w", command, op, args, err)
+	}
+	return nil
+}
+
+// Run runs an operation as the helper subcommand, which was started by retry
+// as root. Files that it creates are owned by the owner of their directory, so
+// that files in a user's repository remain owned by the user.
+func Run(args []string, stdin io.Reader) error {
+	if len(args) == 0 {
+		return errors.New("missing operation")
+	}
+	op, args := args[0], args[1:]
+	arity := map[string]int{
+		opChmod: 2, opChown: 3, opCopy: 2, opLink: 2, opMkdir: 2, opMkdirAll: 2,
+		opRemove: 1, opRemoveAll: 1, opRename: 2, opSymlink: 2, opWriteFile: 2,
+	}
+	n, ok := arity[op]
+	if !ok {
+		return fmt.Errorf("invalid operation %q", op)
+	}
+	if len(args) != n {
+		return fmt.Errorf("%s needs %d arguments", op, n)
+	}
+
+	var created string
+	switch op {
+	case opCopy, opLink, opSymlink:
+		created = args[1]
+	case opMkdir, opMkdirAll, opWriteFile:
+		created = args[0]
+	}
+	if _, err := os.Lstat(created); created != "" && !os.IsNotExist(err) {
+		created = ""
+	}
+	if err := run(op, args, stdin); err != nil {
+		return err
+	}
+	if created == "" {
+		return nil
+	}
+	return inheritOwner(created)
+}
+
+func run(op string, args []string, stdin io.Reader) error {
+	switch op {
+	case opChown:
+		uid, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		gid, err := strconv.Atoi(args[2])
+		if err != nil {
+			return err
+		}
+		return os.Lchown(args[0], uid, gid)
+	case opCopy:
+		return copy.File(args[0], args[1])
+	case opLink:
+		return os.Link(args[0], args[1])
+	case opRemove:
+		return os.Remove(args[0])
+	case opRemoveAll:
+		return os.RemoveAll(args[0])
+	case opRename:
+		return os.Rename(args[0], args[1])
+	case opSymlink:
+		return os.Symlink(args[0], args[1])
+	}
+
+	// The remaining operations have a mode
+	n, err := strconv.ParseUint(args[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode %q", args[1])
+	}
+	mode := os.FileMode(n)
+	switch op {
+	case opChmod:
+		return os.Chmod(args[0], mode)
+	case opMkdir:
+		return os.Mkdir(args[0], mode)
+	case opMkdirAll:
+		return os.MkdirAll(args[0], mode)
+	}
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(args[0], content, mode)
+}
+
+// inheritOwner changes the owner of the file or link at p to the owner of its directory
+func inheritOwner(p string) error {
+	fileInfo, err := os.Stat(filepath.Dir(p))
+	if err != nil {
+		return err
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok || stat.Uid == 0 {
+		return nil
+	}
+	return os.Lchown(p, int(stat.Uid), int(stat.Gid))
+}
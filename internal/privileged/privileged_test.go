@@ -0,0 +1,71 @@
+package privileged
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// TestRunWritesFilesOwnedByTheirDirectoryOwner verifies files that the helper
+// creates as root in a user's directory are owned by the user
+func TestRunWritesFilesOwnedByTheirDirectoryOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of files requires root")
+	}
+	dir := t.TempDir()
+	if err := os.Chown(dir, 65534, 65534); err != nil {
+		t.Fatalf("Failed to change owner: %v", err)
+	}
+	p := filepath.Join(dir, "file")
+	args := []string{opWriteFile, p, strconv.FormatUint(0600, 10)}
+	if err := Run(args, bytes.NewReader([]byte("content"))); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(p)
+	if err != nil || string(content) != "content" {
+		t.Fatalf("ReadFile() = %q, %v, want \"content\"", content, err)
+	}
+	fileInfo, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if stat := fileInfo.Sys().(*syscall.Stat_t); stat.Uid != 65534 || stat.Gid != 65534 {
+		t.Errorf("Owner = %d:%d, want 65534:65534", stat.Uid, stat.Gid)
+	}
+	if fileInfo.Mode().Perm() != 0600 {
+		t.Errorf("Mode = %v, want 0600", fileInfo.Mode().Perm())
+	}
+}
+
+// TestRunRejectsInvalidOperations verifies the helper only runs known operations with the right arguments
+func TestRunRejectsInvalidOperations(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{"exec", "/bin/sh"},
+		{opRemove},
+		{opRename, "/a"},
+		{opChmod, t.TempDir(), "rw"},
+	}
+	for _, args := range tests {
+		if err := Run(args, bytes.NewReader(nil)); err == nil {
+			t.Errorf("Run(%q) succeeded, want an error", args)
+		}
+	}
+}
+
+// TestSetCommandRejectsUnknownCommands verifies only supported escalation commands are accepted
+func TestSetCommandRejectsUnknownCommands(t *testing.T) {
+	defer SetCommand("")
+	for _, s := range []string{"", CommandSudo, CommandPkexec} {
+		if err := SetCommand(s); err != nil {
+			t.Errorf("SetCommand(%q) failed: %v", s, err)
+		}
+	}
+	if err := SetCommand("su"); err == nil {
+		t.Error("SetCommand(\"su\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,55 @@
+// Package prompt asks the user questions on the terminal
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// AssumeYes answers yes to every question without asking
+	AssumeYes = false
+
+	stdin = bufio.NewReader(os.Stdin)
+)
+
+// Confirm asks a yes/no question and returns true if the answer is yes.
+// It returns false without asking if standard input is closed.
+func Confirm(question string) bool {
+	if AssumeYes {
+		return true
+	}
+	// Write to standard error, which keeps standard output parsable when printing JSON
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Ask asks a question and returns the answer, or false if standard input is closed
+func Ask(question string) (string, bool) {
+	fmt.Fprintf(os.Stderr, "%s ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return "", false
+	}
+	return strings.TrimSpace(answer), true
+}
+
+// Show prints text that helps to answer a question
+func Show(text string) {
+	fmt.Fprint(os.Stderr, text)
+}
+
+// IsTerminal returns true if standard input is a terminal, so that questions can be answered
+func IsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
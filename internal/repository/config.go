@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/andornaut/gog/internal/ignore"
+	"github.com/andornaut/gog/internal/output"
+)
+
+const (
+	// ConfigFileName is the name of the configuration file at the root of a repository
+	ConfigFileName = ".gog.toml"
+	// IgnoreFileName is the name of the file at the root of a repository which
+	// lists paths that are not linked, using .gitignore syntax
+	IgnoreFileName = ".gogignore"
+)
+
+// defaultIgnorePatterns are prepended to IgnoreFileName, so they can be negated
+var defaultIgnorePatterns = []string{"/.gitattributes", "/.gitignore", "/LICENSE", "/README.md"}
+
+// Link modes
+const (
+	// ModeSymlink links files with symbolic links
+	ModeSymlink = "symlink"
+	// ModeCopy copies files, and records their content so that changes on either side can be synced
+	ModeCopy = "copy"
+	// ModeHardlink links files with hard links, or copies them if they are on a different device than the repository
+	ModeHardlink = "hardlink"
+)
+
+// Config is a repository's configuration, which is read from ConfigFileName.
+// Environment variables override the corresponding settings.
+type Config struct {
+	// Ignore is a list of regular expressions which match repository-relative
+	// paths of files that are not linked ($GOG_IGNORE_FILES_REGEX)
+	Ignore []string `toml:"ignore"`
+	// Backups is false if .gog backups should not be created ($GOG_DO_NOT_CREATE_BACKUPS)
+	Backups *bool `toml:"backups"`
+	// Priority decides which repository's file is linked when several
+	// repositories contain the same file. Higher priorities win. ($GOG_REPOSITORY_PRIORITY)
+	Priority int `toml:"priority"`
+	// Mode is how files are linked
+	Mode string `toml:"mode"`
+	// Modes overrides Mode for repository-relative paths of files or directories
+	Modes map[string]string `toml:"modes"`
+	// Fold is a list of repository-relative paths of directories which are
+	// linked as a single symbolic link, instead of linking each of their files
+	Fold []string `toml:"fold"`
+	// Recipients are the age public keys of other machines, to which files are
+	// encrypted in addition to the local identity's key
+	Recipients []string `toml:"recipients"`
+	// Variables are path variables, in addition to $HOME, $XDG_CONFIG_HOME and
+	// $XDG_DATA_HOME, which are substituted for the directories that they name.
+	// An environment variable of the same name overrides the directory.
+	Variables map[string]string `toml:"variables"`
+	Hooks     Hooks             `toml:"hooks"`
+
+	ignoreRegexes []*regexp.Regexp
+	ignoreFile    *ignore.Matcher
+}
+
+// Hooks are shell commands which are run in the repository's directory
+type Hooks struct {
+	PreApply  string `toml:"pre_apply"`
+	PostApply string `toml:"post_apply"`
+}
+
+var configs = make(map[string]*Config)
+
+// LoadConfig returns the configuration of the given repository, or the
+// default configuration if the repository does not have a configuration file
+func LoadConfig(repoPath string) (*Config, error) {
+	if c, ok := configs[repoPath]; ok {
+		return c, nil
+	}
+
+	ignoreFile, err := ignore.Load(filepath.Join(repoPath, IgnoreFileName), defaultIgnorePatterns...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{ignoreFile: ignoreFile}
+	p := filepath.Join(repoPath, ConfigFileName)
+	md, err := toml.DecodeFile(p, c)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", p, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("invalid configuration file %s: unknown setting %q", p, undecoded[0].String())
+	}
+
+	if c.Mode == "" {
+		c.Mode = ModeSymlink
+	}
+	if err := validateMode(c.Mode); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", p, err)
+	}
+	for relPath, mode := range c.Modes {
+		if err := validateMode(mode); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %s: %w", p, relPath, err)
+		}
+	}
+	if err := validateVariables(c.Variables); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", p, err)
+	}
+	for _, s := range c.Ignore {
+		r, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: invalid ignore regular expression %q: %w", p, s, err)
+		}
+		c.ignoreRegexes = append(c.ignoreRegexes, r)
+	}
+
+	configs[repoPath] = c
+	return c, nil
+}
+
+// BackupsEnabled returns true if .gog backups should be created
+func (c *Config) BackupsEnabled() bool {
+	return c.Backups == nil || *c.Backups
+}
+
+// ModeFor returns how the file at the given repository-relative path is linked
+func (c *Config) ModeFor(relPath string) string {
+	mode, longest := c.Mode, -1
+	for p, m := range c.Modes {
+		p = strings.Trim(p, "/")
+		if (relPath == p || strings.HasPrefix(relPath, p+"/")) && len(p) > longest {
+			mode, longest = m, len(p)
+		}
+	}
+	if mode == "" {
+		return ModeSymlink
+	}
+	return mode
+}
+
+// IsFolded returns true if the directory at the given repository-relative path
+// is linked as a single symbolic link
+func (c *Config) IsFolded(relPath string) bool {
+	for _, p := range c.Fold {
+		if strings.Trim(p, "/") == relPath {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIgnored returns true if the given repository-relative path matches one of the ignore patterns
+func (c *Config) IsIgnored(relPath string) bool {
+	for _, r := range c.ignoreRegexes {
+		if r.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIgnoredByFile returns true if the given repository-relative path, which is
+// a directory if isDir is true, matches IgnoreFileName or the default ignore patterns
+func (c *Config) IsIgnoredByFile(relPath string, isDir bool) bool {
+	if c.ignoreFile == nil {
+		c.ignoreFile = ignore.New(defaultIgnorePatterns)
+	}
+	return c.ignoreFile.Match(relPath, isDir)
+}
+
+func validateMode(mode string) error {
+	switch mode {
+	case ModeSymlink, ModeCopy, ModeHardlink:
+		return nil
+	}
+	return fmt.Errorf("invalid mode %q (must be one of: %s, %s, %s)", mode, ModeSymlink, ModeCopy, ModeHardlink)
+}
+
+// RunHook runs the given hook command, if any, in the repository's directory
+func RunHook(repoPath, name, command string) error {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	if DryRun {
+		output.Emit(output.Event{Action: output.ActionHook, Repository: filepath.Base(repoPath), Reason: command, DryRun: true},
+			fmt.Sprintf("Would run %s hook: %s", name, command))
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), "GOG_REPOSITORY="+filepath.Base(repoPath), "GOG_REPOSITORY_PATH="+repoPath)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	if !output.IsText() {
+		// Keep standard output parsable
+		cmd.Stdout = os.Stderr
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadConfigReadsConfigFile verifies settings are read from the repository's configuration file
+func TestLoadConfigReadsConfigFile(t *testing.T) {
+	repoPath := t.TempDir()
+	content := `ignore = ['\.swp$']
+backups = false
+priority = 10
+fold = ["$HOME/.config/nvim/"]
+
+[hooks]
+post_apply = "echo done"
+`
+	if err := os.WriteFile(filepath.Join(repoPath, ConfigFileName), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	c, err := LoadConfig(repoPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() failed: %v", err)
+	}
+	if c.BackupsEnabled() {
+		t.Error("Backups should be disabled")
+	}
+	if c.Priority != 10 {
+		t.Errorf("Priority = %d, want 10", c.Priority)
+	}
+	if c.Mode != ModeSymlink {
+		t.Errorf("Mode = %q, want %q", c.Mode, ModeSymlink)
+	}
+	if c.Hooks.PostApply != "echo done" {
+		t.Errorf("Hooks.PostApply = %q, want %q", c.Hooks.PostApply, "echo done")
+	}
+	if !c.IsIgnored("$HOME/.vimrc.swp") || c.IsIgnored("$HOME/.vimrc") {
+		t.Error("IsIgnored() should only match the ignore patterns")
+	}
+	if !c.IsFolded("$HOME/.config/nvim") || c.IsFolded("$HOME/.config") {
+		t.Error("IsFolded() should only match the fold paths")
+	}
+}
+
+// TestLoadConfigDefaults verifies repositories without a configuration file use defaults
+func TestLoadConfigDefaults(t *testing.T) {
+	c, err := LoadConfig(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadConfig() failed: %v", err)
+	}
+	if !c.BackupsEnabled() {
+		t.Error("Backups should be enabled by default")
+	}
+	if c.IsIgnored("README.md") {
+		t.Error("Nothing should be ignored by default")
+	}
+}
+
+// TestLoadConfigRejectsInvalidSettings verifies typos and invalid values are reported
+func TestLoadConfigRejectsInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unknown setting", "backup = false\n"},
+		{"invalid mode", "mode = \"teleport\"\n"},
+		{"invalid regular expression", "ignore = [\"(\"]\n"},
+		{"relative variable", "[variables]\nCODE = \"code\"\n"},
+		{"built-in variable", "[variables]\nHOME = \"/home/bob\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoPath := t.TempDir()
+			if err := os.WriteFile(filepath.Join(repoPath, ConfigFileName), []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+			_, err := LoadConfig(repoPath)
+			if err == nil || !strings.Contains(err.Error(), ConfigFileName) {
+				t.Errorf("LoadConfig() error = %v, want invalid configuration file error", err)
+			}
+		})
+	}
+}
+
+// TestSkipFuncAppliesIgnoreFile verifies `add` does not copy files matched by IgnoreFileName
+func TestSkipFuncAppliesIgnoreFile(t *testing.T) {
+	repoPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, IgnoreFileName), []byte("cache/\n*.log\n!keep.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to create ignore file: %v", err)
+	}
+	extDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(extDir, "cache"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	skip := skipFunc(repoPath)
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"cache", true},
+		{"debug.log", true},
+		{"keep.log", false},
+		{"config", false},
+	}
+	for _, tt := range tests {
+		extPath := filepath.Join(extDir, tt.name)
+		intPath := filepath.Join(repoPath, "$HOME", ".app", tt.name)
+		if result := skip(extPath, intPath); result != tt.expected {
+			t.Errorf("skip(%q) = %v, want %v", tt.name, result, tt.expected)
+		}
+	}
+}
+
+// TestModeForUsesMostSpecificPath verifies per-path modes override the repository's mode
+func TestModeForUsesMostSpecificPath(t *testing.T) {
+	c := &Config{
+		Mode: ModeCopy,
+		Modes: map[string]string{
+			"$HOME/.config":      ModeSymlink,
+			"$HOME/.config/app/": ModeCopy,
+		},
+	}
+	tests := map[string]string{
+		"$HOME/.bashrc":               ModeCopy,
+		"$HOME/.config/nvim/init.vim": ModeSymlink,
+		"$HOME/.config/app/settings":  ModeCopy,
+		"$HOME/.config/application":   ModeSymlink,
+	}
+	for relPath, expected := range tests {
+		if mode := c.ModeFor(relPath); mode != expected {
+			t.Errorf("ModeFor(%q) = %q, want %q", relPath, mode, expected)
+		}
+	}
+}
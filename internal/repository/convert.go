@@ -1,36 +1,112 @@
 package repository
 
 import (
+	"fmt"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
-// ToInternalPath converts an external path to one within the given repository
+// TemplateSuffix is the suffix of repository files which are rendered as
+// templates instead of being linked. It is not part of the external path.
+const TemplateSuffix = ".tmpl"
+
+// EncryptedSuffix is the suffix of repository files which are encrypted, and
+// decrypted instead of being linked. It is not part of the external path.
+const EncryptedSuffix = ".age"
+
+var (
+	// targetRoot is the directory to which files are applied instead of "/",
+	// or an empty string if they are applied to the filesystem itself
+	targetRoot string
+	// targeted is true if files are applied to another root or home directory,
+	// in which case the current user's environment does not describe their location
+	targeted bool
+)
+
+// SetTarget applies files to the given root directory, e.g. a mounted image,
+// instead of "/", and to the given home directory within it instead of the
+// current user's. Empty values keep the defaults.
+func SetTarget(root, home string) error {
+	for _, p := range []string{root, home} {
+		if p != "" && !path.IsAbs(p) {
+			return fmt.Errorf("invalid target directory %q (must be an absolute path)", p)
+		}
+	}
+	if root != "" && path.Clean(root) != "/" {
+		targetRoot = path.Clean(root)
+		targeted = true
+	}
+	if home != "" {
+		homeDir = path.Clean(home)
+		targeted = true
+	}
+	return nil
+}
+
+// LinkTarget returns the target of a symbolic link at extPath to intPath.
+// Links within a target root are relative, so that they are valid both inside
+// it, e.g. when an image is booted, and outside of it.
+func LinkTarget(intPath, extPath string) (string, error) {
+	if targetRoot == "" {
+		return intPath, nil
+	}
+	if !isAtOrBelow(intPath, targetRoot) {
+		return "", fmt.Errorf("cannot link to %s, because it is outside of the target root %s (set GOG_HOME to a directory within it, or use mode = \"copy\")", intPath, targetRoot)
+	}
+	return filepath.Rel(filepath.Dir(extPath), intPath)
+}
+
+// ToInternalPath converts an external path to one within the given repository,
+// in which the directory named by a path variable is replaced by the variable,
+// e.g. "$HOME" or "$XDG_CONFIG_HOME". If the repository contains a template, an encrypted file or variants of the
+// external path, then the path of the one that is linked on the current machine is returned.
 func ToInternalPath(repoPath, p string) string {
-	if strings.HasPrefix(p, homeDir) {
-		p = strings.TrimPrefix(p, homeDir)
-		p = path.Join("$HOME", p)
+	if targetRoot != "" && isAtOrBelow(p, targetRoot) {
+		p = path.Join("/", strings.TrimPrefix(p, targetRoot))
 	}
-	return path.Join(repoPath, p)
+	intPath := path.Join(repoPath, substituteVariable(pathVariables(repoPath), p))
+	if selected, _ := SelectVariant(intPath); selected != "" {
+		return selected
+	}
+	return intPath
 }
 
 // ToExternalPath converts an internal path to one outside of the given repository
 func ToExternalPath(repoPath, p string) string {
 	p = strings.TrimPrefix(p, repoPath+"/")
+	dir, name := path.Split(p)
+	p = dir + externalName(name)
 
-	// Only expand $HOME specifically, not arbitrary environment variables
+	// Only expand path variables, not arbitrary environment variables
 	// This prevents path injection attacks via malicious environment variables
-	if strings.HasPrefix(p, "$HOME") {
-		p = strings.Replace(p, "$HOME", homeDir, 1)
-	}
+	p = expandVariables(pathVariables(repoPath), p)
 
-	// If p does not start with $HOME and was expanded, then TrimPrefix stripped leading "/", so we must re-add it now.
+	// If p does not start with a path variable, then TrimPrefix stripped leading "/", so we must re-add it now.
 	if !strings.HasPrefix(p, "/") {
 		p = "/" + p
 	}
+	if targetRoot != "" {
+		p = path.Join(targetRoot, p)
+	}
 	return p
 }
 
+// IsTemplate returns true if the given internal path is rendered as a template
+func IsTemplate(intPath string) bool {
+	return strings.HasSuffix(intPath, TemplateSuffix)
+}
+
+// IsEncrypted returns true if the given internal path is decrypted instead of being linked
+func IsEncrypted(intPath string) bool {
+	return strings.HasSuffix(intPath, EncryptedSuffix)
+}
+
+// HomeDir returns the current user's home directory, which is substituted for $HOME
+func HomeDir() string {
+	return homeDir
+}
+
 // SetHomeDirForTest sets homeDir for testing and returns the original value.
 // This should only be used in tests to mock the home directory.
 func SetHomeDirForTest(dir string) string {
@@ -38,3 +114,12 @@ func SetHomeDirForTest(dir string) string {
 	homeDir = dir
 	return original
 }
+
+// SetTargetRootForTest sets targetRoot for testing and returns the original value.
+// This should only be used in tests.
+func SetTargetRootForTest(root string) string {
+	original := targetRoot
+	targetRoot = root
+	targeted = root != ""
+	return original
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -67,3 +69,65 @@ func TestPathConversionRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+// TestToInternalPathSubstitutesVariablesOnComponentBoundaries verifies that path
+// variables only replace whole directories, and that the most specific one wins
+func TestToInternalPathSubstitutesVariablesOnComponentBoundaries(t *testing.T) {
+	defer SetHomeDirForTest(SetHomeDirForTest("/home/alice"))
+	t.Setenv("XDG_CONFIG_HOME", "/cfg/alice")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("CODE", "")
+	repoPath := t.TempDir()
+	config := "[variables]\nCODE = \"$HOME/code\"\n"
+	if err := os.WriteFile(filepath.Join(repoPath, ConfigFileName), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	tests := []struct {
+		p    string
+		want string
+	}{
+		{"/home/alice/.bashrc", "$HOME/.bashrc"},
+		{"/home/alice2/.bashrc", "home/alice2/.bashrc"},
+		{"/home/alice/.config/nvim/init.vim", "$HOME/.config/nvim/init.vim"},
+		{"/home/alice/.local/share/app/db", "$HOME/.local/share/app/db"},
+		{"/cfg/alice/nvim/init.vim", "$XDG_CONFIG_HOME/nvim/init.vim"},
+		{"/cfg/alice2/nvim/init.vim", "cfg/alice2/nvim/init.vim"},
+		{"/home/alice/code/project/.envrc", "$CODE/project/.envrc"},
+		{"/home/alice/codex/.envrc", "$HOME/codex/.envrc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.p, func(t *testing.T) {
+			intPath := ToInternalPath(repoPath, tt.p)
+			if want := filepath.Join(repoPath, tt.want); intPath != want {
+				t.Errorf("ToInternalPath(%q) = %q, want %q", tt.p, intPath, want)
+			}
+			if extPath := ToExternalPath(repoPath, intPath); extPath != tt.p {
+				t.Errorf("ToExternalPath(%q) = %q, want %q", intPath, extPath, tt.p)
+			}
+		})
+	}
+
+	// A variable name must match the whole first component
+	if got := ToExternalPath(repoPath, filepath.Join(repoPath, "$HOMEX/.bashrc")); got != "/$HOMEX/.bashrc" {
+		t.Errorf("ToExternalPath() = %q, want \"/$HOMEX/.bashrc\"", got)
+	}
+}
+
+// TestSetTargetRejectsRelativeDirectories verifies the target root and home must be absolute
+func TestSetTargetRejectsRelativeDirectories(t *testing.T) {
+	defer SetHomeDirForTest(SetHomeDirForTest("/home/testuser"))
+	defer SetTargetRootForTest(SetTargetRootForTest(""))
+
+	for _, dirs := range [][2]string{{"mnt/image", ""}, {"", "home/dev"}} {
+		if err := SetTarget(dirs[0], dirs[1]); err == nil {
+			t.Errorf("SetTarget(%q, %q) succeeded, want an error", dirs[0], dirs[1])
+		}
+	}
+	if err := SetTarget("/mnt/image/", "/home/dev"); err != nil {
+		t.Fatalf("SetTarget() failed: %v", err)
+	}
+	if got := ToExternalPath("/repo", "/repo/$HOME/.bashrc"); got != "/mnt/image/home/dev/.bashrc" {
+		t.Errorf("ToExternalPath() = %q, want \"/mnt/image/home/dev/.bashrc\"", got)
+	}
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andornaut/gog/internal/git"
+	"github.com/andornaut/gog/internal/output"
+	"github.com/andornaut/gog/internal/secret"
+)
+
+const (
+	// textconvDriver is the name of the git diff driver which decrypts encrypted files
+	textconvDriver = "gog-age"
+	// gitAttributesFileName is the name of the file which assigns textconvDriver to encrypted files
+	gitAttributesFileName = ".gitattributes"
+)
+
+var textconvConfigured = make(map[string]bool)
+
+// AddEncryptedPaths encrypts the given files into the given repository
+func AddEncryptedPaths(repoPath string, paths []string) error {
+	return syncRepository(repoPath, paths, addEncryptedPath)
+}
+
+func addEncryptedPath(repoPath, targetPath string) error {
+	if err := validateTargetPath(targetPath); err != nil {
+		return err
+	}
+	extFileInfo, err := os.Stat(targetPath)
+	if err != nil {
+		return err
+	}
+	if !extFileInfo.Mode().IsRegular() {
+		return fmt.Errorf("cannot encrypt %s: only files can be encrypted", targetPath)
+	}
+
+	// The file may already be in the repository, either encrypted, in which case
+	// it is encrypted again, or unencrypted and linked to it
+	plainPath := strings.TrimSuffix(ToInternalPath(repoPath, targetPath), EncryptedSuffix)
+	if IsTemplate(plainPath) {
+		return fmt.Errorf("%s is rendered from the template %s (templates cannot be encrypted)", targetPath, plainPath)
+	}
+	intPath := plainPath + EncryptedSuffix
+	if DryRun {
+		output.Emit(output.Event{Action: output.ActionEncrypted, ExtPath: targetPath, IntPath: intPath, Repository: filepath.Base(repoPath), DryRun: true},
+			fmt.Sprintf("Would encrypt: %s -> %s", targetPath, intPath))
+		return nil
+	}
+
+	c, err := LoadConfig(repoPath)
+	if err != nil {
+		return err
+	}
+	plaintext, err := os.ReadFile(targetPath)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := secret.Encrypt(plaintext, c.Recipients)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(intPath, ciphertext, 0644); err != nil {
+		return err
+	}
+	output.Emit(output.Event{Action: output.ActionEncrypted, ExtPath: targetPath, IntPath: intPath, Repository: filepath.Base(repoPath)},
+		fmt.Sprintf("Encrypted: %s -> %s", targetPath, intPath))
+
+	if _, err := os.Lstat(plainPath); err == nil {
+		// Remove the unencrypted copy, which is linked again as the encrypted file
+		if err := git.Run(repoPath, "rm", "-q", "--cached", "--ignore-unmatch", plainPath); err != nil {
+			return err
+		}
+		if err := os.Remove(plainPath); err != nil {
+			return err
+		}
+	}
+	return ConfigureTextconv(repoPath)
+}
+
+// ConfigureTextconv configures git to decrypt encrypted files with `gog
+// textconv`, so that `gog git diff` and `gog git log -p` show their content
+func ConfigureTextconv(repoPath string) error {
+	if DryRun || textconvConfigured[repoPath] {
+		return nil
+	}
+	p := filepath.Join(repoPath, gitAttributesFileName)
+	attributes, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	line := fmt.Sprintf("*%s diff=%s", EncryptedSuffix, textconvDriver)
+	if !bytes.Contains(attributes, []byte(line)) {
+		if len(attributes) > 0 && !bytes.HasSuffix(attributes, []byte("\n")) {
+			attributes = append(attributes, '\n')
+		}
+		attributes = append(attributes, line+"\n"...)
+		if err := os.WriteFile(p, attributes, 0644); err != nil {
+			return err
+		}
+		if err := git.Run(repoPath, "add", gitAttributesFileName); err != nil {
+			return err
+		}
+	}
+
+	// The diff driver is configured in each clone, because git does not read it from the repository
+	key := fmt.Sprintf("diff.%s.textconv", textconvDriver)
+	if current, _ := git.Output(repoPath, "config", "--local", "--get", key); strings.TrimSpace(current) == "" {
+		if err := git.Run(repoPath, "config", "--local", key, "gog textconv"); err != nil {
+			return err
+		}
+	}
+	textconvConfigured[repoPath] = true
+	return nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/secret"
+)
+
+// TestAddPathsEncryptsFileThatIsEncryptedInRepository verifies adding a file
+// without --encrypt does not copy it in clear text over its encrypted version
+func TestAddPathsEncryptsFileThatIsEncryptedInRepository(t *testing.T) {
+	testHome := t.TempDir()
+	defer SetHomeDirForTest(SetHomeDirForTest(testHome))
+	repoPath := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repoPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+	t.Setenv("GOG_IDENTITY_FILE", filepath.Join(t.TempDir(), "identity.txt"))
+	if _, err := secret.GenerateIdentity(); err != nil {
+		t.Fatalf("GenerateIdentity() failed: %v", err)
+	}
+
+	ciphertext, err := secret.Encrypt([]byte("old secret\n"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() failed: %v", err)
+	}
+	intPath := filepath.Join(repoPath, "$HOME", ".netrc"+EncryptedSuffix)
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, ciphertext, 0644); err != nil {
+		t.Fatalf("Failed to create encrypted file: %v", err)
+	}
+	extPath := filepath.Join(testHome, ".netrc")
+	if err := os.WriteFile(extPath, []byte("new secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := AddPaths(repoPath, []string{extPath}); err != nil {
+		t.Fatalf("AddPaths() failed: %v", err)
+	}
+	content, err := os.ReadFile(intPath)
+	if err != nil {
+		t.Fatalf("Failed to read encrypted file: %v", err)
+	}
+	if string(content) == "new secret\n" {
+		t.Fatal("The encrypted file was replaced by the file in clear text")
+	}
+	plaintext, err := secret.Decrypt(content)
+	if err != nil || string(plaintext) != "new secret\n" {
+		t.Errorf("Decrypt() = %q, %v, want \"new secret\\n\"", plaintext, err)
+	}
+	if _, err := os.Lstat(filepath.Join(repoPath, "$HOME", ".netrc")); err == nil {
+		t.Error("The file was added in clear text")
+	}
+}
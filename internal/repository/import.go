@@ -0,0 +1,409 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andornaut/gog/internal/git"
+	"github.com/andornaut/gog/internal/output"
+)
+
+// Dotfile managers whose layouts can be imported
+const (
+	ImportStow    = "stow"
+	ImportChezmoi = "chezmoi"
+	ImportYadm    = "yadm"
+)
+
+// importedFile is a file in another dotfile manager's layout
+type importedFile struct {
+	// src describes where the file is in the imported layout
+	src string
+	// relPath is the file's path relative to the home directory, including
+	// gog's variant condition and suffixes
+	relPath string
+	// mode is the file's permissions, which the layout records or implies
+	mode os.FileMode
+	read func() ([]byte, error)
+}
+
+// Import copies the files of a directory which is laid out for another dotfile
+// manager into the given repository, in gog's layout
+func Import(repoPath, from, dir string) error {
+	var files []importedFile
+	var err error
+	switch from {
+	case ImportStow:
+		files, err = stowFiles(dir)
+	case ImportChezmoi:
+		files, err = chezmoiFiles(dir)
+	case ImportYadm:
+		files, err = yadmFiles(dir)
+	default:
+		return fmt.Errorf("invalid import source %q (must be one of: stow, chezmoi, yadm)", from)
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := importFile(repoPath, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importFile(repoPath string, f importedFile) error {
+	// Variants have the same external path as the file without their condition,
+	// so only the directory is converted
+	extPath := filepath.Join(homeDir, f.relPath)
+	intPath := filepath.Join(ToInternalPath(repoPath, filepath.Dir(extPath)), filepath.Base(extPath))
+	e := output.Event{Action: output.ActionImported, ExtPath: extPath, IntPath: intPath, Repository: filepath.Base(repoPath)}
+	if _, err := os.Lstat(intPath); err == nil {
+		printImportSkipped(f.src, "the repository already contains "+intPath)
+		return nil
+	}
+	if DryRun {
+		e.DryRun = true
+		output.Emit(e, fmt.Sprintf("Would import: %s -> %s", f.src, intPath))
+		return nil
+	}
+
+	content, err := f.read()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.src, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(intPath, content, f.mode.Perm()); err != nil {
+		return err
+	}
+	// The mode of a new file is restricted by the umask
+	if err := os.Chmod(intPath, f.mode); err != nil {
+		return err
+	}
+	if err := setMetadata(repoPath, intPath, FileMetadata{Mode: formatMode(f.mode)}); err != nil {
+		return err
+	}
+	if err := git.Run(repoPath, "add", "--force", intPath); err != nil {
+		return err
+	}
+	output.Emit(e, fmt.Sprintf("Imported: %s -> %s", f.src, intPath))
+	return nil
+}
+
+// stowFiles returns the files of the packages in a stow directory, which are
+// linked relative to the home directory. Like `stow --dotfiles`, a "dot-"
+// prefix is replaced by ".".
+func stowFiles(dir string) ([]importedFile, error) {
+	packages, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []importedFile
+	for _, pkg := range packages {
+		if !pkg.IsDir() || pkg.Name() == ".git" {
+			continue
+		}
+		pkgDir := filepath.Join(dir, pkg.Name())
+		err := filepath.Walk(pkgDir, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath := strings.TrimPrefix(p, pkgDir+"/")
+			if isStowIgnored(relPath) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if !info.Mode().IsRegular() {
+				printImportSkipped(p, "only regular files can be imported")
+				return nil
+			}
+			components := strings.Split(relPath, "/")
+			for i, c := range components {
+				if rest, ok := strings.CutPrefix(c, "dot-"); ok {
+					components[i] = "." + rest
+				}
+			}
+			files = append(files, importedFile{
+				src:     p,
+				relPath: filepath.Join(components...),
+				mode:    specialMode(info.Mode()),
+				read:    func() ([]byte, error) { return os.ReadFile(p) },
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// isStowIgnored returns true if stow ignores the given package-relative path by default
+func isStowIgnored(relPath string) bool {
+	name := filepath.Base(relPath)
+	switch name {
+	case ".git", ".gitignore", ".gitmodules", ".stow-local-ignore", "CVS", "RCS":
+		return true
+	}
+	if !strings.Contains(relPath, "/") && (strings.HasPrefix(name, "README") || strings.HasPrefix(name, "LICENSE") || name == "COPYING") {
+		return true
+	}
+	return strings.HasSuffix(name, "~") || strings.HasPrefix(name, ".#") || (strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#"))
+}
+
+// chezmoiTemplateData maps chezmoi's template data to gog's `TemplateData`
+var chezmoiTemplateData = strings.NewReplacer(
+	".chezmoi.os", ".OS",
+	".chezmoi.arch", ".Arch",
+	".chezmoi.hostname", ".Hostname",
+	".chezmoi.username", ".User",
+	".chezmoi.homeDir", ".Home",
+)
+
+// chezmoiFiles returns the files of a chezmoi source directory, whose names
+// encode their attributes, e.g. "private_dot_netrc" or "executable_script.sh.tmpl"
+func chezmoiFiles(dir string) ([]importedFile, error) {
+	if root, err := os.ReadFile(filepath.Join(dir, ".chezmoiroot")); err == nil {
+		dir = filepath.Join(dir, strings.TrimSpace(string(root)))
+	}
+	var files []importedFile
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		relPath := strings.TrimPrefix(p, dir+"/")
+		if strings.HasPrefix(info.Name(), ".") {
+			// chezmoi ignores these files, except for its own configuration
+			if strings.HasPrefix(info.Name(), ".chezmoi") && !strings.Contains(relPath, "/") {
+				switch info.Name() {
+				case ".chezmoiroot", ".chezmoiversion":
+				default:
+					printImportSkipped(p, "chezmoi's configuration cannot be imported")
+				}
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), "remove_") {
+				printImportSkipped(p, "chezmoi removes this directory")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		components := strings.Split(relPath, "/")
+		for i, c := range components[:len(components)-1] {
+			components[i] = chezmoiDirName(c)
+		}
+		name, mode, reason := chezmoiFileName(info.Name())
+		if reason != "" {
+			printImportSkipped(p, reason)
+			return nil
+		}
+		read := func() ([]byte, error) { return os.ReadFile(p) }
+		if strings.HasSuffix(name, TemplateSuffix) {
+			read = func() ([]byte, error) {
+				content, err := os.ReadFile(p)
+				if err != nil {
+					return nil, err
+				}
+				content = []byte(chezmoiTemplateData.Replace(string(content)))
+				if strings.Contains(string(content), ".chezmoi.") {
+					printImportWarning(p, "the template uses chezmoi's template data, which must be replaced by gog's")
+				}
+				return content, nil
+			}
+		}
+		components[len(components)-1] = name
+		files = append(files, importedFile{src: p, relPath: filepath.Join(components...), mode: mode, read: read})
+		return nil
+	})
+	return files, err
+}
+
+// chezmoiDirName returns the name of a directory without chezmoi's attribute prefixes
+func chezmoiDirName(name string) string {
+	for _, prefix := range []string{"external_", "exact_", "private_", "readonly_"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	if rest, ok := strings.CutPrefix(name, "literal_"); ok {
+		return rest
+	}
+	if rest, ok := strings.CutPrefix(name, "dot_"); ok {
+		return "." + rest
+	}
+	return name
+}
+
+// chezmoiFileName returns the name of a file in gog's layout and the mode that
+// is implied by its chezmoi attributes, or the reason why it cannot be imported
+func chezmoiFileName(name string) (string, os.FileMode, string) {
+	for prefix, reason := range map[string]string{
+		"run_":     "scripts cannot be imported",
+		"modify_":  "chezmoi modifies this file with a script",
+		"remove_":  "chezmoi removes this file",
+		"symlink_": "symbolic links cannot be imported",
+	} {
+		if strings.HasPrefix(name, prefix) {
+			return "", 0, reason
+		}
+	}
+
+	attributes := make(map[string]bool)
+	name = strings.TrimPrefix(name, "create_")
+	for _, prefix := range []string{"encrypted_", "private_", "readonly_", "empty_", "executable_"} {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			name = rest
+			attributes[prefix] = true
+		}
+	}
+	mode := os.FileMode(0644)
+	if attributes["executable_"] {
+		mode = 0755
+	}
+	if attributes["private_"] {
+		mode &^= 0077
+	}
+	if attributes["readonly_"] {
+		mode &^= 0222
+	}
+	encrypted := attributes["encrypted_"]
+	if rest, ok := strings.CutPrefix(name, "literal_"); ok {
+		name = rest
+	} else if rest, ok := strings.CutPrefix(name, "dot_"); ok {
+		name = "." + rest
+	}
+
+	template := false
+	if rest, ok := strings.CutSuffix(name, ".literal"); ok {
+		name = rest
+	} else if rest, ok := strings.CutSuffix(name, TemplateSuffix); ok {
+		name, template = rest, true
+	}
+	if encrypted {
+		switch {
+		case strings.HasSuffix(name, ".asc"):
+			return "", 0, "only files that are encrypted with age can be imported"
+		case template:
+			return "", 0, "encrypted templates cannot be imported"
+		}
+		// gog decrypts files with age, like chezmoi, so the file is imported as is
+		return strings.TrimSuffix(name, EncryptedSuffix) + EncryptedSuffix, mode, ""
+	}
+	if template {
+		name += TemplateSuffix
+	}
+	return name, mode, ""
+}
+
+// yadmFiles returns the files that are committed to a yadm repository, which
+// is usually the bare repository at ~/.local/share/yadm/repo.git
+func yadmFiles(dir string) ([]importedFile, error) {
+	gitDir := dir
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		gitDir = filepath.Join(dir, ".git")
+	}
+	out, err := git.Output(dir, "--git-dir", gitDir, "ls-tree", "-r", "-z", "--full-tree", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the yadm repository %s: %w", dir, err)
+	}
+
+	var files []importedFile
+	for _, line := range strings.Split(out, "\x00") {
+		// Each line is "<mode> <type> <object>\t<path>"
+		info, relPath, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		src := fmt.Sprintf("%s:%s", dir, relPath)
+		switch {
+		case strings.HasPrefix(relPath, ".config/yadm/"), strings.HasPrefix(relPath, ".local/share/yadm/"):
+			printImportSkipped(src, "yadm's configuration cannot be imported")
+			continue
+		case fields[0] != "100644" && fields[0] != "100755":
+			printImportSkipped(src, "only regular files can be imported")
+			continue
+		case strings.Contains(filepath.Dir(relPath), VariantSeparator):
+			printImportSkipped(src, "alternate directories cannot be imported")
+			continue
+		}
+		name, reason := yadmFileName(filepath.Base(relPath))
+		if reason != "" {
+			printImportSkipped(src, reason)
+			continue
+		}
+
+		mode := os.FileMode(0644)
+		if fields[0] == "100755" {
+			mode = 0755
+		}
+		object := fields[2]
+		files = append(files, importedFile{
+			src:     src,
+			relPath: filepath.Join(filepath.Dir(relPath), name),
+			mode:    mode,
+			read: func() ([]byte, error) {
+				content, err := git.Output(dir, "--git-dir", gitDir, "cat-file", "blob", object)
+				return []byte(content), err
+			},
+		})
+	}
+	return files, nil
+}
+
+// yadmFileName converts the condition of a yadm alternate file, e.g.
+// "config##os.Linux", to a gog variant, or returns the reason why it cannot be
+func yadmFileName(name string) (string, string) {
+	i := strings.Index(name, VariantSeparator)
+	if i <= 0 {
+		return name, ""
+	}
+	base := name[:i]
+	var conditions []string
+	for _, condition := range strings.Split(name[i+len(VariantSeparator):], ",") {
+		key, value, _ := strings.Cut(condition, ".")
+		switch key {
+		case "default":
+		case "e", "extension":
+			// Only editors use the extension
+		case "o", "os":
+			conditions = append(conditions, VariantOS+"."+strings.ToLower(value))
+		case "h", "hostname":
+			conditions = append(conditions, VariantHostname+"."+value)
+		default:
+			return "", fmt.Sprintf("yadm's %q condition cannot be imported", key)
+		}
+	}
+	switch len(conditions) {
+	case 0:
+		return base, ""
+	case 1:
+		return base + VariantSeparator + conditions[0], ""
+	}
+	return "", "alternates with more than one condition cannot be imported"
+}
+
+func printImportSkipped(src, reason string) {
+	output.Emit(output.Event{Action: output.ActionSkipped, Reason: reason}, fmt.Sprintf("Skipped: %s (%s)", src, reason))
+}
+
+func printImportWarning(src, reason string) {
+	output.EmitError(output.Event{Action: output.ActionImported, Reason: reason}, fmt.Sprintf("WARNING %s: %s", src, reason))
+}
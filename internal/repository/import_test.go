@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestImportConvertsStowPackages verifies stow packages are copied relative to $HOME with their modes
+func TestImportConvertsStowPackages(t *testing.T) {
+	defer SetHomeDirForTest(SetHomeDirForTest(t.TempDir()))
+	repoPath := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repoPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+
+	stowDir := t.TempDir()
+	files := map[string]os.FileMode{
+		"bash/dot-bashrc":       0644,
+		"bash/README.md":        0644,
+		"ssh/dot-ssh/config":    0600,
+		"ssh/dot-ssh/config.~1": 0644,
+	}
+	for relPath, mode := range files {
+		p := filepath.Join(stowDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(relPath), mode); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+	}
+
+	if err := Import(repoPath, ImportStow, stowDir); err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	want := map[string]os.FileMode{
+		"$HOME/.bashrc":        0644,
+		"$HOME/.ssh/config":    0600,
+		"$HOME/.ssh/config.~1": 0644,
+	}
+	for relPath, mode := range want {
+		fileInfo, err := os.Stat(filepath.Join(repoPath, relPath))
+		if err != nil {
+			t.Errorf("%s was not imported: %v", relPath, err)
+			continue
+		}
+		if fileInfo.Mode() != mode {
+			t.Errorf("Mode of %s = %v, want %v", relPath, fileInfo.Mode(), mode)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "$HOME/README.md")); err == nil {
+		t.Error("README.md was imported, want it to be ignored")
+	}
+	m, err := LoadMetadata(repoPath)
+	if err != nil {
+		t.Fatalf("LoadMetadata() failed: %v", err)
+	}
+	if m["$HOME/.ssh/config"].Mode != "0600" {
+		t.Errorf("Mode of $HOME/.ssh/config = %q, want \"0600\"", m["$HOME/.ssh/config"].Mode)
+	}
+}
+
+// TestChezmoiFileName verifies chezmoi's attributes are converted to names and modes
+func TestChezmoiFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     string
+		wantMode os.FileMode
+		skipped  bool
+	}{
+		{"dot_bashrc", ".bashrc", 0644, false},
+		{"private_dot_netrc", ".netrc", 0600, false},
+		{"private_executable_dot_script", ".script", 0700, false},
+		{"readonly_dot_profile", ".profile", 0444, false},
+		{"dot_gitconfig.tmpl", ".gitconfig.tmpl", 0644, false},
+		{"literal_dot_file.tmpl.literal", "dot_file.tmpl", 0644, false},
+		{"encrypted_private_dot_key.age", ".key.age", 0600, false},
+		{"encrypted_dot_key.asc", "", 0, true},
+		{"run_once_install.sh", "", 0, true},
+		{"symlink_dot_vimrc", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, mode, reason := chezmoiFileName(tt.name)
+			if tt.skipped {
+				if reason == "" {
+					t.Errorf("chezmoiFileName(%q) = %q, want it to be skipped", tt.name, name)
+				}
+				return
+			}
+			if name != tt.want || mode != tt.wantMode || reason != "" {
+				t.Errorf("chezmoiFileName(%q) = %q, %v, %q, want %q, %v", tt.name, name, mode, reason, tt.want, tt.wantMode)
+			}
+		})
+	}
+}
+
+// TestYadmFileName verifies yadm's alternate conditions are converted to variants
+func TestYadmFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		skipped bool
+	}{
+		{".vimrc", ".vimrc", false},
+		{".vimrc##default", ".vimrc", false},
+		{".vimrc##os.Darwin", ".vimrc##os.darwin", false},
+		{".vimrc##h.laptop", ".vimrc##hostname.laptop", false},
+		{".vimrc##os.Linux,e.vim", ".vimrc##os.linux", false},
+		{".vimrc##template", "", true},
+		{".vimrc##os.Linux,h.laptop", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, reason := yadmFileName(tt.name)
+			if tt.skipped {
+				if reason == "" {
+					t.Errorf("yadmFileName(%q) = %q, want it to be skipped", tt.name, name)
+				}
+				return
+			}
+			if name != tt.want || reason != "" {
+				t.Errorf("yadmFileName(%q) = %q, %q, want %q", tt.name, name, reason, tt.want)
+			}
+		})
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/andornaut/gog/internal/copy"
 	"github.com/andornaut/gog/internal/git"
+	"github.com/andornaut/gog/internal/output"
+	"github.com/andornaut/gog/internal/privileged"
 )
 
 // Add adds a new repository
@@ -75,28 +78,95 @@ func addPath(repoPath, targetPath string) error {
 		// Already added
 		return nil
 	}
+	if IsTemplate(intPath) {
+		return fmt.Errorf("%s is rendered from the template %s (edit the template instead)", targetPath, intPath)
+	}
+	if IsEncrypted(intPath) {
+		// The repository contains the file encrypted, so it is encrypted again
+		// instead of being copied over the ciphertext in clear text
+		return addEncryptedPath(repoPath, targetPath)
+	}
 
 	extFileInfo, err := os.Stat(extPath)
 	if err != nil {
 		return err
 	}
+	if DryRun {
+		output.Emit(output.Event{Action: output.ActionCopied, ExtPath: extPath, IntPath: intPath, Repository: filepath.Base(repoPath), DryRun: true},
+			fmt.Sprintf("Would copy: %s -> %s", extPath, intPath))
+		return nil
+	}
 	if extFileInfo.IsDir() {
-		return copy.Dir(extPath, intPath, shouldSkip)
+		skip := skipFunc(repoPath)
+		if err := copy.Dir(extPath, intPath, skip); err != nil {
+			return err
+		}
+		return recordDirMetadata(repoPath, extPath, intPath, skip)
 	}
 
 	// Create the parent directory, because `copy.File` does not create directories
 	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
 		return err
 	}
-	return copy.File(extPath, intPath)
+	// System files which the user is not permitted to read are copied as root
+	if err := privileged.CopyFile(extPath, intPath); err != nil {
+		return err
+	}
+	return recordMetadata(repoPath, extPath, intPath, extFileInfo)
+}
+
+// recordDirMetadata records the metadata of the files below extPath, which
+// were copied to intPath, the same way as `copy.Dir`
+func recordDirMetadata(repoPath, extPath, intPath string, skip copy.SkipFunc) error {
+	return filepath.Walk(extPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == extPath {
+			return nil
+		}
+		dst := filepath.Join(intPath, strings.TrimPrefix(p, extPath+"/"))
+		if info.Mode()&os.ModeSymlink == 0 {
+			switch {
+			case skip(p, dst) && info.IsDir():
+				return filepath.SkipDir
+			case skip(p, dst), info.IsDir():
+				return nil
+			}
+			return recordMetadata(repoPath, p, dst, info)
+		}
+
+		// copy.Dir copies the targets of symbolic links
+		src, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return err
+		}
+		if info, err = os.Stat(src); err != nil {
+			return err
+		}
+		switch {
+		case skip(src, dst):
+			return nil
+		case info.IsDir():
+			return recordDirMetadata(repoPath, src, dst, skip)
+		}
+		return recordMetadata(repoPath, src, dst, info)
+	})
 }
 
 func removePath(repoPath, targetPath string) error {
 	if err := validateTargetPath(targetPath); err != nil {
 		return err
 	}
+	if DryRun {
+		// Files that would be removed are reported when they are unlinked
+		return nil
+	}
 	intPath := ToInternalPath(repoPath, targetPath)
-	return os.RemoveAll(intPath)
+	if err := os.RemoveAll(intPath); err != nil {
+		return err
+	}
+	return forgetMetadata(repoPath, intPath)
 }
 
 type syncFunc func(string, string) error
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/andornaut/gog/internal/git"
+)
+
+// MetadataFileName is the name of the file at the root of a repository which
+// records the permissions and ownership of files, because git only records
+// whether files are executable
+const MetadataFileName = ".gogmeta.toml"
+
+// FileMetadata is the intended permissions and ownership of a repository file
+type FileMetadata struct {
+	// Mode is the octal permissions, including the setuid, setgid and sticky bits, e.g. "0600"
+	Mode string `toml:"mode"`
+	// Owner and Group are names, which are only recorded if they differ from the user who added the file
+	Owner string `toml:"owner,omitempty"`
+	Group string `toml:"group,omitempty"`
+}
+
+// Metadata maps repository-relative paths of files to their FileMetadata
+type Metadata map[string]FileMetadata
+
+var metadata = make(map[string]Metadata)
+
+// LoadMetadata returns the recorded metadata of the given repository's files
+func LoadMetadata(repoPath string) (Metadata, error) {
+	if m, ok := metadata[repoPath]; ok {
+		return m, nil
+	}
+	m := Metadata{}
+	p := filepath.Join(repoPath, MetadataFileName)
+	if _, err := toml.DecodeFile(p, &m); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("invalid metadata file %s: %w", p, err)
+	}
+	for relPath, fm := range m {
+		if _, err := fm.FileMode(); err != nil {
+			return nil, fmt.Errorf("invalid metadata file %s: %s: %w", p, relPath, err)
+		}
+	}
+	metadata[repoPath] = m
+	return m, nil
+}
+
+// FileMode returns the permissions and special mode bits of Mode
+func (fm FileMetadata) FileMode() (os.FileMode, error) {
+	n, err := strconv.ParseUint(fm.Mode, 8, 32)
+	if err != nil || n > 07777 {
+		return 0, fmt.Errorf("invalid mode %q (must be octal, e.g. \"0600\")", fm.Mode)
+	}
+	mode := os.FileMode(n) & os.ModePerm
+	if n&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if n&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if n&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// IDs returns the user and group IDs of Owner and Group, or -1 for those that are not set
+func (fm FileMetadata) IDs() (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if fm.Owner != "" {
+		u, err := user.Lookup(fm.Owner)
+		if err != nil {
+			return 0, 0, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, err
+		}
+	}
+	if fm.Group != "" {
+		g, err := user.LookupGroup(fm.Group)
+		if err != nil {
+			return 0, 0, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, err
+		}
+	}
+	return uid, gid, nil
+}
+
+// ModeDiffers returns true if the permissions or special mode bits of the given file differ from Mode
+func (fm FileMetadata) ModeDiffers(fileInfo os.FileInfo) bool {
+	mode, err := fm.FileMode()
+	return err == nil && specialMode(fileInfo.Mode()) != mode
+}
+
+// OwnerDiffers returns true if the given file is not owned by Owner or Group
+func (fm FileMetadata) OwnerDiffers(fileInfo os.FileInfo) bool {
+	owner, group := ownerOf(fileInfo)
+	return (fm.Owner != "" && owner != fm.Owner) || (fm.Group != "" && group != fm.Group)
+}
+
+// Drift describes how the given file differs from the metadata, or returns
+// an empty string if it does not
+func (fm FileMetadata) Drift(fileInfo os.FileInfo) string {
+	var drift []string
+	if fm.ModeDiffers(fileInfo) {
+		drift = append(drift, fmt.Sprintf("mode %s, want %s", formatMode(fileInfo.Mode()), fm.Mode))
+	}
+	owner, group := ownerOf(fileInfo)
+	if fm.Owner != "" && owner != fm.Owner {
+		drift = append(drift, fmt.Sprintf("owner %s, want %s", owner, fm.Owner))
+	}
+	if fm.Group != "" && group != fm.Group {
+		drift = append(drift, fmt.Sprintf("group %s, want %s", group, fm.Group))
+	}
+	return strings.Join(drift, ", ")
+}
+
+// recordMetadata records the metadata of the file at extPath, which was
+// added to the repository at intPath, unless git preserves it
+func recordMetadata(repoPath, extPath, intPath string, fileInfo os.FileInfo) error {
+	fm := FileMetadata{Mode: formatMode(fileInfo.Mode())}
+	if owner, group := ownerOf(fileInfo); owner != "" {
+		if u, err := user.Current(); err == nil && owner != u.Username {
+			fm.Owner = owner
+		}
+		if g, err := user.LookupGroupId(strconv.Itoa(os.Getgid())); err == nil && group != g.Name {
+			fm.Group = group
+		}
+	}
+	return setMetadata(repoPath, intPath, fm)
+}
+
+// setMetadata records the metadata of the repository file at intPath, unless git preserves it
+func setMetadata(repoPath, intPath string, fm FileMetadata) error {
+	m, err := LoadMetadata(repoPath)
+	if err != nil {
+		return err
+	}
+	perm, err := fm.FileMode()
+	if err != nil {
+		return err
+	}
+	relPath := strings.TrimPrefix(intPath, repoPath+"/")
+	_, recorded := m[relPath]
+	switch {
+	case (perm == 0644 || perm == 0755) && fm.Owner == "" && fm.Group == "":
+		// git restores the mode, assuming the default umask
+		if !recorded {
+			return nil
+		}
+		delete(m, relPath)
+	case m[relPath] == fm:
+		return nil
+	default:
+		m[relPath] = fm
+	}
+	return saveMetadata(repoPath, m)
+}
+
+// forgetMetadata removes the metadata of the files at or below intPath
+func forgetMetadata(repoPath, intPath string) error {
+	m, err := LoadMetadata(repoPath)
+	if err != nil {
+		return err
+	}
+	relPath := strings.TrimPrefix(intPath, repoPath+"/")
+	changed := false
+	for p := range m {
+		if p == relPath || strings.HasPrefix(p, relPath+"/") {
+			delete(m, p)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return saveMetadata(repoPath, m)
+}
+
+func saveMetadata(repoPath string, m Metadata) error {
+	var b bytes.Buffer
+	b.WriteString("# Permissions and ownership of files, which are applied by `gog apply`\n\n")
+	if err := toml.NewEncoder(&b).Encode(m); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, MetadataFileName), b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return git.Run(repoPath, "add", MetadataFileName)
+}
+
+// specialMode returns the permissions and the setuid, setgid and sticky bits of mode
+func specialMode(mode os.FileMode) os.FileMode {
+	return mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// formatMode formats the permissions and special mode bits in octal, e.g. "4755"
+func formatMode(mode os.FileMode) string {
+	n := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		n |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		n |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		n |= 01000
+	}
+	return fmt.Sprintf("%04o", n)
+}
+
+// ownerOf returns the names of the user and group that own a file, or their IDs if they do not have names
+func ownerOf(fileInfo os.FileInfo) (owner, group string) {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+	owner, group = strconv.Itoa(int(stat.Uid)), strconv.Itoa(int(stat.Gid))
+	if u, err := user.LookupId(owner); err == nil {
+		owner = u.Username
+	}
+	if g, err := user.LookupGroupId(group); err == nil {
+		group = g.Name
+	}
+	return owner, group
+}
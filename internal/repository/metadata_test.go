@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestRecordMetadataRecordsModesThatGitDoesNotPreserve verifies only non-default modes are recorded, and forgotten when files are removed
+func TestRecordMetadataRecordsModesThatGitDoesNotPreserve(t *testing.T) {
+	repoPath := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repoPath).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+
+	modes := map[string]os.FileMode{
+		"$HOME/.bashrc":       0644,
+		"$HOME/.ssh/id":       0600,
+		"$HOME/bin/setuid":    0755 | os.ModeSetuid,
+		"$HOME/bin/script.sh": 0755,
+	}
+	extDir := t.TempDir()
+	for relPath, mode := range modes {
+		extPath := filepath.Join(extDir, filepath.Base(relPath))
+		if err := os.WriteFile(extPath, nil, 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		if err := os.Chmod(extPath, mode); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+		fileInfo, err := os.Stat(extPath)
+		if err != nil {
+			t.Fatalf("Failed to stat test file: %v", err)
+		}
+		if err := recordMetadata(repoPath, extPath, filepath.Join(repoPath, relPath), fileInfo); err != nil {
+			t.Fatalf("recordMetadata() failed: %v", err)
+		}
+	}
+
+	delete(metadata, repoPath)
+	m, err := LoadMetadata(repoPath)
+	if err != nil {
+		t.Fatalf("LoadMetadata() failed: %v", err)
+	}
+	want := map[string]string{"$HOME/.ssh/id": "0600", "$HOME/bin/setuid": "4755"}
+	if len(m) != len(want) {
+		t.Errorf("LoadMetadata() = %v, want %v", m, want)
+	}
+	for relPath, mode := range want {
+		if m[relPath].Mode != mode {
+			t.Errorf("Mode of %s = %q, want %q", relPath, m[relPath].Mode, mode)
+		}
+		if fileMode, err := m[relPath].FileMode(); err != nil || fileMode != modes[relPath] {
+			t.Errorf("FileMode() of %s = %v, %v, want %v", relPath, fileMode, err, modes[relPath])
+		}
+	}
+
+	if err := forgetMetadata(repoPath, filepath.Join(repoPath, "$HOME/bin")); err != nil {
+		t.Fatalf("forgetMetadata() failed: %v", err)
+	}
+	delete(metadata, repoPath)
+	if m, _ = LoadMetadata(repoPath); len(m) != 1 {
+		t.Errorf("LoadMetadata() after forgetMetadata() = %v, want only $HOME/.ssh/id", m)
+	}
+}
+
+// TestLoadMetadataRejectsInvalidModes verifies modes must be octal permissions
+func TestLoadMetadataRejectsInvalidModes(t *testing.T) {
+	for _, mode := range []string{"rw-------", "600a", "17777"} {
+		repoPath := t.TempDir()
+		content := "[\"$HOME/.netrc\"]\nmode = \"" + mode + "\"\n"
+		if err := os.WriteFile(filepath.Join(repoPath, MetadataFileName), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create metadata file: %v", err)
+		}
+		if _, err := LoadMetadata(repoPath); err == nil {
+			t.Errorf("LoadMetadata() should fail for mode %q", mode)
+		}
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 var (
 	// BaseDir is the root data directory
 	BaseDir string
+	// DryRun prints the files that would be copied instead of modifying the repository
+	DryRun  = false
 	homeDir string
 )
 
@@ -44,9 +47,69 @@ func List() ([]string, error) {
 	return repoNames, nil
 }
 
+// SortByPriority sorts repository paths in order of decreasing priority.
+// Repositories named in $GOG_REPOSITORY_PRIORITY come first, in the order in
+// which they are listed, followed by all other repositories in order of their
+// configured priority, and then in their given order.
+func SortByPriority(repoPaths []string) {
+	less := byPriority()
+	sort.SliceStable(repoPaths, func(i, j int) bool {
+		return less(repoPaths[i], repoPaths[j])
+	})
+}
+
+// HasHigherPriority returns true if the repository at repoPath would be sorted
+// before the repository at otherRepoPath by SortByPriority
+func HasHigherPriority(repoPath, otherRepoPath string) bool {
+	return byPriority()(repoPath, otherRepoPath)
+}
+
+// byPriority returns a function which returns true if the first repository
+// path has a higher priority than the second
+func byPriority() func(string, string) bool {
+	ranks := make(map[string]int)
+	for _, name := range strings.Split(os.Getenv("GOG_REPOSITORY_PRIORITY"), ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := ranks[name]; name != "" && !ok {
+			ranks[name] = len(ranks)
+		}
+	}
+	rank := func(repoPath string) int {
+		if r, ok := ranks[filepath.Base(repoPath)]; ok {
+			return r
+		}
+		return len(ranks)
+	}
+	priority := func(repoPath string) int {
+		c, err := LoadConfig(repoPath)
+		if err != nil {
+			return 0
+		}
+		return c.Priority
+	}
+	return func(a, b string) bool {
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return priority(a) > priority(b)
+	}
+}
+
 // RootPath returns an absolute filesystem path which corresponds to the given
-// repository name or the default repository's path if the given name is empty
+// repository name or the default repository's path if the given name is empty.
+// The repository's configuration file is loaded and validated.
 func RootPath(name string) (string, error) {
+	p, err := rootPath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := LoadConfig(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func rootPath(name string) (string, error) {
 	if name == "" {
 		return GetDefault()
 	}
@@ -107,7 +170,7 @@ func getFirst() (string, error) {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if entry.IsDir() && validateRepoName(entry.Name()) == nil {
 			return filepath.Join(BaseDir, entry.Name()), nil
 		}
 	}
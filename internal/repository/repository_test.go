@@ -64,3 +64,18 @@ func TestRootPathDirectoryTraversal(t *testing.T) {
 		t.Error("RootPath should reject paths outside BaseDir")
 	}
 }
+
+// TestSortByPriority verifies repositories named in $GOG_REPOSITORY_PRIORITY come first
+func TestSortByPriority(t *testing.T) {
+	t.Setenv("GOG_REPOSITORY_PRIORITY", "work, ,personal,work")
+
+	repoPaths := []string{"/gog/alpha", "/gog/personal", "/gog/beta", "/gog/work"}
+	SortByPriority(repoPaths)
+
+	expected := []string{"/gog/work", "/gog/personal", "/gog/alpha", "/gog/beta"}
+	for i := range expected {
+		if repoPaths[i] != expected[i] {
+			t.Fatalf("SortByPriority() = %v, want %v", repoPaths, expected)
+		}
+	}
+}
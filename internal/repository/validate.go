@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/andornaut/gog/internal/copy"
 	"github.com/andornaut/gog/internal/git"
 )
 
@@ -46,6 +47,19 @@ func validateTargetPath(p string) error {
 	return nil
 }
 
-func shouldSkip(extPath, _ string) bool {
-	return strings.HasPrefix(extPath, BaseDir) || strings.HasSuffix(extPath, ".gog")
+// skipFunc returns a function which skips gog's own files and the files that
+// are ignored by the repository's configuration when copying a directory
+func skipFunc(repoPath string) copy.SkipFunc {
+	return func(extPath, intPath string) bool {
+		if strings.HasPrefix(extPath, BaseDir) || strings.HasSuffix(extPath, ".gog") {
+			return true
+		}
+		c, err := LoadConfig(repoPath)
+		if err != nil {
+			return false
+		}
+		fileInfo, err := os.Stat(extPath)
+		isDir := err == nil && fileInfo.IsDir()
+		return c.IsIgnoredByFile(strings.TrimPrefix(intPath, repoPath+"/"), isDir)
+	}
 }
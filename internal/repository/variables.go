@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Path variables which are always substituted
+const (
+	VariableHome          = "HOME"
+	VariableXDGConfigHome = "XDG_CONFIG_HOME"
+	VariableXDGDataHome   = "XDG_DATA_HOME"
+)
+
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pathVariable is a variable that is substituted for the directory that it names
+type pathVariable struct {
+	name  string
+	value string
+	// isDefault is true if value is the variable's default location below the
+	// home directory, in which case paths are stored relative to $HOME instead,
+	// as they were before the variable was supported
+	isDefault bool
+}
+
+// pathVariables returns the variables which are substituted in the given
+// repository's paths: the built-in variables, and those in its configuration.
+// Only these variables are expanded, rather than arbitrary environment variables,
+// so that a repository's paths cannot be redirected by the environment.
+func pathVariables(repoPath string) []pathVariable {
+	variables := []pathVariable{
+		{name: VariableHome, value: homeDir},
+		xdgVariable(VariableXDGConfigHome, ".config"),
+		xdgVariable(VariableXDGDataHome, ".local/share"),
+	}
+	if c, err := LoadConfig(repoPath); err == nil {
+		names := make([]string, 0, len(c.Variables))
+		for name := range c.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := c.Variables[name]
+			if env := os.Getenv(name); path.IsAbs(env) && !targeted {
+				value = env
+			}
+			value = expandVariables(variables, value)
+			if !path.IsAbs(value) {
+				// The value starts with an unknown variable
+				continue
+			}
+			variables = append(variables, pathVariable{name: name, value: path.Clean(value)})
+		}
+	}
+	return variables
+}
+
+// xdgVariable returns an XDG base directory variable, which defaults to the
+// given directory below the home directory if it is not set to an absolute path,
+// or if files are applied to another root or home directory
+func xdgVariable(name, defaultDir string) pathVariable {
+	defaultValue := path.Join(homeDir, defaultDir)
+	value := os.Getenv(name)
+	if !path.IsAbs(value) || targeted {
+		value = defaultValue
+	}
+	value = path.Clean(value)
+	return pathVariable{name: name, value: value, isDefault: value == defaultValue}
+}
+
+// substituteVariable replaces the directory at the start of the absolute path
+// p with the variable which names it, e.g. "/home/alice/.bashrc" becomes
+// "$HOME/.bashrc", but "/home/alice2/.bashrc" is unchanged. The variable with
+// the longest value wins, so that nested directories are stored by their own variable.
+func substituteVariable(variables []pathVariable, p string) string {
+	var best *pathVariable
+	for i, v := range variables {
+		if v.isDefault || v.value == "/" || !isAtOrBelow(p, v.value) {
+			continue
+		}
+		if best == nil || len(v.value) > len(best.value) {
+			best = &variables[i]
+		}
+	}
+	if best == nil {
+		return p
+	}
+	return path.Join("$"+best.name, strings.TrimPrefix(p, best.value))
+}
+
+// expandVariables replaces the variable in the first component of p with its
+// value. Paths that start with an unknown variable are unchanged.
+func expandVariables(variables []pathVariable, p string) string {
+	first, rest, _ := strings.Cut(p, "/")
+	name, ok := strings.CutPrefix(first, "$")
+	if !ok {
+		return p
+	}
+	for _, v := range variables {
+		if v.name == name {
+			return path.Join(v.value, rest)
+		}
+	}
+	return p
+}
+
+// isAtOrBelow returns true if the path p is dir or a path within it
+func isAtOrBelow(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// validateVariables returns an error if the given configured path variables
+// cannot be substituted
+func validateVariables(variables map[string]string) error {
+	for name, value := range variables {
+		switch {
+		case !variableNameRegex.MatchString(name):
+			return fmt.Errorf("invalid variable name %q", name)
+		case name == VariableHome || name == VariableXDGConfigHome || name == VariableXDGDataHome:
+			return fmt.Errorf("variable %s is built in and cannot be configured", name)
+		case !path.IsAbs(value) && !strings.HasPrefix(value, "$"):
+			return fmt.Errorf("variable %s: %q must be an absolute path or start with a variable, e.g. \"$HOME/code\"", name, value)
+		}
+	}
+	return nil
+}
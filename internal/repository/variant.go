@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// VariantSeparator separates the name of a file from the condition under
+// which it is linked, e.g. "config##hostname.laptop" or "config##os.linux"
+const VariantSeparator = "##"
+
+// Variant conditions
+const (
+	VariantHostname = "hostname"
+	VariantOS       = "os"
+)
+
+var hostname, _ = os.Hostname()
+
+// dirNames caches the names of the files in the directories that SelectVariant
+// read, while CacheDirNames is in effect
+var dirNames map[string][]string
+
+// CacheDirNames caches directory listings, so that selecting the variants of
+// all files in a tree reads each directory only once, until stop is called.
+// The repository's files must not be created or deleted in the meantime.
+func CacheDirNames() (stop func()) {
+	if dirNames != nil {
+		// An outer walk already caches them
+		return func() {}
+	}
+	dirNames = make(map[string][]string)
+	return func() { dirNames = nil }
+}
+
+// readDirNames returns the names of the files in dir
+func readDirNames(dir string) []string {
+	if names, ok := dirNames[dir]; ok {
+		return names
+	}
+	var names []string
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+	}
+	if dirNames != nil {
+		dirNames[dir] = names
+	}
+	return names
+}
+
+// SelectVariant returns the internal path of the file which best matches the
+// current machine among the files which have the same external path as intPath,
+// or an empty string if none of them matches. hasVariants is true if intPath
+// is a variant or has variants.
+func SelectVariant(intPath string) (selected string, hasVariants bool) {
+	dir, name := path.Split(intPath)
+	extName := externalName(name)
+	var candidates []string
+	for _, n := range readDirNames(dir) {
+		if externalName(n) == extName {
+			candidates = append(candidates, n)
+		}
+	}
+	hasVariants = len(candidates) > 1 || extName != trimSuffixes(name)
+	if len(candidates) == 0 {
+		return "", hasVariants
+	}
+
+	// Prefer the most specific match, and then the first by name
+	sort.Slice(candidates, func(i, j int) bool {
+		if variantScore(candidates[i]) != variantScore(candidates[j]) {
+			return variantScore(candidates[i]) > variantScore(candidates[j])
+		}
+		return candidates[i] < candidates[j]
+	})
+	if variantScore(candidates[0]) == 0 {
+		return "", hasVariants
+	}
+	return path.Join(dir, candidates[0]), hasVariants
+}
+
+// externalName returns a repository file's name without its suffixes or variant condition
+func externalName(name string) string {
+	name = trimSuffixes(name)
+	if i := strings.LastIndex(name, VariantSeparator); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// variantScore returns how specifically a repository file's name matches the
+// current machine, or 0 if it does not match
+func variantScore(name string) int {
+	name = trimSuffixes(name)
+	i := strings.LastIndex(name, VariantSeparator)
+	if i <= 0 {
+		// Not a variant
+		return 1
+	}
+	key, value, _ := strings.Cut(name[i+len(VariantSeparator):], ".")
+	switch {
+	case key == VariantHostname && value == hostname:
+		return 3
+	case key == VariantOS && value == runtime.GOOS:
+		return 2
+	}
+	return 0
+}
+
+// trimSuffixes returns a repository file's name without its encrypted or template suffix
+func trimSuffixes(name string) string {
+	name = strings.TrimSuffix(name, EncryptedSuffix)
+	return strings.TrimSuffix(name, TemplateSuffix)
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestSelectVariant verifies the most specific matching variant is selected
+func TestSelectVariant(t *testing.T) {
+	originalHostname := hostname
+	defer func() { hostname = originalHostname }()
+	hostname = "laptop"
+
+	tests := []struct {
+		name     string
+		files    []string
+		expected string
+	}{
+		{"plain file", []string{"config"}, "config"},
+		{"os variant", []string{"config", "config##os." + runtime.GOOS}, "config##os." + runtime.GOOS},
+		{"hostname variant", []string{"config", "config##os." + runtime.GOOS, "config##hostname.laptop"}, "config##hostname.laptop"},
+		{"other hostname", []string{"config", "config##hostname.desktop"}, "config"},
+		{"template variant", []string{"config", "config##hostname.laptop" + TemplateSuffix}, "config##hostname.laptop" + TemplateSuffix},
+		{"encrypted variant", []string{"config", "config##os." + runtime.GOOS + EncryptedSuffix}, "config##os." + runtime.GOOS + EncryptedSuffix},
+		{"no match", []string{"config##os.plan9", "config##hostname.desktop"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+					t.Fatalf("Failed to create file: %v", err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(dir, "unrelated##os."+runtime.GOOS), nil, 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+
+			expected := ""
+			if tt.expected != "" {
+				expected = filepath.Join(dir, tt.expected)
+			}
+			for _, name := range tt.files {
+				selected, hasVariants := SelectVariant(filepath.Join(dir, name))
+				if selected != expected {
+					t.Errorf("SelectVariant(%q) = %q, want %q", name, selected, expected)
+				}
+				if hasVariants != (len(tt.files) > 1 || name != "config") {
+					t.Errorf("SelectVariant(%q) hasVariants = %v", name, hasVariants)
+				}
+			}
+		})
+	}
+}
+
+// TestCacheDirNames verifies directories are only read once while their listings are cached
+func TestCacheDirNames(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config")
+	if err := os.WriteFile(p, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	variant := filepath.Join(dir, "config##os."+runtime.GOOS)
+
+	stop := CacheDirNames()
+	if selected, _ := SelectVariant(p); selected != p {
+		t.Fatalf("SelectVariant() = %q, want %q", selected, p)
+	}
+	if err := os.WriteFile(variant, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if selected, _ := SelectVariant(p); selected != p {
+		t.Errorf("SelectVariant() = %q, want the cached %q", selected, p)
+	}
+	stop()
+	if selected, _ := SelectVariant(p); selected != variant {
+		t.Errorf("SelectVariant() = %q, want %q", selected, variant)
+	}
+}
+
+// TestVariantPathConversion verifies variant conditions are not part of external paths
+func TestVariantPathConversion(t *testing.T) {
+	originalHomeDir := homeDir
+	defer func() { homeDir = originalHomeDir }()
+	homeDir = "/home/testuser"
+
+	repoPath := t.TempDir()
+	intPath := filepath.Join(repoPath, "$HOME", ".config", "app##os."+runtime.GOOS)
+	if err := os.MkdirAll(filepath.Dir(intPath), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(intPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	if result := ToExternalPath(repoPath, intPath); result != "/home/testuser/.config/app" {
+		t.Errorf("ToExternalPath() = %q, want %q", result, "/home/testuser/.config/app")
+	}
+	if result := ToInternalPath(repoPath, "/home/testuser/.config/app"); result != intPath {
+		t.Errorf("ToInternalPath() = %q, want %q", result, intPath)
+	}
+}
@@ -0,0 +1,142 @@
+// Package secret encrypts repository files with age, using keys which are
+// stored outside of the repositories
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// IdentityFilePath returns the path of the file that contains the age identities
+// (private keys) with which files are decrypted ($GOG_IDENTITY_FILE)
+func IdentityFilePath() string {
+	if p := os.Getenv("GOG_IDENTITY_FILE"); p != "" {
+		return p
+	}
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "gog", "identity.txt")
+}
+
+// GenerateIdentity creates an identity file, unless one exists, and returns its public key
+func GenerateIdentity() (string, error) {
+	p := IdentityFilePath()
+	if identities, err := loadIdentities(); err == nil {
+		recipients := recipientsOf(identities)
+		if len(recipients) == 0 {
+			return "", fmt.Errorf("identity file %s does not contain an X25519 identity", p)
+		}
+		return recipients[0].String(), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return "", err
+	}
+	content := fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient(), identity)
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		return "", err
+	}
+	return identity.Recipient().String(), nil
+}
+
+// Encrypt encrypts plaintext to the public keys of the local identities, and
+// to the given public keys, e.g. those of other machines
+func Encrypt(plaintext []byte, publicKeys []string) ([]byte, error) {
+	var recipients []age.Recipient
+	if identities, err := loadIdentities(); err == nil {
+		for _, r := range recipientsOf(identities) {
+			recipients = append(recipients, r)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(publicKeys) > 0 {
+		r, err := age.ParseRecipients(strings.NewReader(strings.Join(publicKeys, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipients: %w", err)
+		}
+		recipients = append(recipients, r...)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no keys to encrypt with (run `gog keygen` to create %s)", IdentityFilePath())
+	}
+
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Decrypt decrypts ciphertext with the local identities
+func Decrypt(ciphertext []byte) ([]byte, error) {
+	identities, err := loadIdentities()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// DecryptFile decrypts the file at p with the local identities
+func DecryptFile(p string) ([]byte, error) {
+	ciphertext, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", p, err)
+	}
+	return plaintext, nil
+}
+
+func loadIdentities() ([]age.Identity, error) {
+	p := IdentityFilePath()
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read identity file (run `gog keygen` to create it): %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity file %s: %w", p, err)
+	}
+	return identities, nil
+}
+
+func recipientsOf(identities []age.Identity) []*age.X25519Recipient {
+	var recipients []*age.X25519Recipient
+	for _, i := range identities {
+		if x, ok := i.(*age.X25519Identity); ok {
+			recipients = append(recipients, x.Recipient())
+		}
+	}
+	return recipients
+}
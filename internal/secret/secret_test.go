@@ -0,0 +1,51 @@
+package secret
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// TestEncryptAndDecrypt verifies files are encrypted to the local identity and to other recipients
+func TestEncryptAndDecrypt(t *testing.T) {
+	t.Setenv("GOG_IDENTITY_FILE", filepath.Join(t.TempDir(), "identity.txt"))
+
+	if _, err := Encrypt([]byte("secret"), nil); err == nil {
+		t.Error("Encrypt() should fail without any keys")
+	}
+
+	publicKey, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity() failed: %v", err)
+	}
+	info, err := os.Stat(IdentityFilePath())
+	if err != nil {
+		t.Fatalf("Identity file was not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Identity file permissions = %o, want 600", info.Mode().Perm())
+	}
+	if again, err := GenerateIdentity(); err != nil || again != publicKey {
+		t.Errorf("GenerateIdentity() = %q, %v, want the existing key %q", again, err, publicKey)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	ciphertext, err := Encrypt([]byte("secret"), []string{other.Recipient().String()})
+	if err != nil {
+		t.Fatalf("Encrypt() failed: %v", err)
+	}
+
+	plaintext, err := Decrypt(ciphertext)
+	if err != nil || string(plaintext) != "secret" {
+		t.Errorf("Decrypt() = %q, %v, want %q", plaintext, err, "secret")
+	}
+	if _, err := age.Decrypt(bytes.NewReader(ciphertext), other); err != nil {
+		t.Errorf("Other recipient cannot decrypt: %v", err)
+	}
+}
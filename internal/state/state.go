@@ -0,0 +1,176 @@
+// Package state records the files that gog manages outside of its repositories
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// Entry is a file that gog manages at an external path
+type Entry struct {
+	ExtPath string `json:"external_path"`
+	IntPath string `json:"internal_path"`
+	// Mode is how the file is linked, or "template" or "encrypted" if it is
+	// rendered from a template or decrypted
+	Mode string `json:"mode"`
+	// Hash is the SHA-256 digest of the file's content when both of its copies
+	// were last in sync, or of the rendered template
+	Hash string `json:"hash,omitempty"`
+	// BackupPath is the .gog backup of the file that was replaced, if any
+	BackupPath string `json:"backup_path,omitempty"`
+	// Time is when the entry last changed
+	Time time.Time `json:"time"`
+}
+
+// State is the record of the files that gog manages for a repository
+type State struct {
+	// Entries are keyed by external path
+	Entries map[string]*Entry `json:"entries"`
+
+	path string
+	// changed is true if the entries changed since they were loaded or saved
+	changed bool
+}
+
+var states = make(map[string]*State)
+
+// Dir returns the directory in which state files are stored
+func Dir() string {
+	// Repository names cannot begin with ".", so this cannot conflict with a repository
+	return filepath.Join(repository.BaseDir, ".state")
+}
+
+// Load returns the state of the given repository, which is empty if no state has been saved
+func Load(repoPath string) (*State, error) {
+	if s, ok := states[repoPath]; ok {
+		return s, nil
+	}
+
+	s := &State{
+		Entries: make(map[string]*Entry),
+		path:    filepath.Join(Dir(), filepath.Base(repoPath)+".json"),
+	}
+	b, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, s); err != nil {
+			return nil, fmt.Errorf("invalid state file %s: %w", s.path, err)
+		}
+	}
+	states[repoPath] = s
+	return s, nil
+}
+
+// Discard discards the states that were loaded, so that they are loaded again
+// from their files, e.g. after the files were restored
+func Discard() {
+	states = make(map[string]*State)
+}
+
+// SaveAll saves the states that changed since they were loaded, so that each
+// state file is written once per command rather than once per file
+func SaveAll() error {
+	paths := make([]string, 0, len(states))
+	for repoPath := range states {
+		paths = append(paths, repoPath)
+	}
+	sort.Strings(paths)
+	for _, repoPath := range paths {
+		if s := states[repoPath]; s.changed {
+			if err := s.Save(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the entry for the given external path, or nil if it is not managed by gog
+func (s *State) Get(extPath string) *Entry {
+	return s.Entries[extPath]
+}
+
+// List returns all entries sorted by external path
+func (s *State) List() []*Entry {
+	entries := make([]*Entry, 0, len(s.Entries))
+	for _, e := range s.Entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ExtPath < entries[j].ExtPath
+	})
+	return entries
+}
+
+// Set adds or replaces the entry for e.ExtPath
+func (s *State) Set(e Entry) {
+	s.Entries[e.ExtPath] = &e
+	s.changed = true
+}
+
+// Delete removes the entry for the given external path
+func (s *State) Delete(extPath string) {
+	delete(s.Entries, extPath)
+	s.changed = true
+}
+
+// Save writes the state to its file
+func (s *State) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and then rename it, so that the state file is never partially written
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return err
+	}
+	s.changed = false
+	return nil
+}
+
+// Hash returns the SHA-256 digest of the content of the file at p
+func Hash(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashBytes returns the SHA-256 digest of the given content
+func HashBytes(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
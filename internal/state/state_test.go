@@ -0,0 +1,80 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andornaut/gog/internal/repository"
+)
+
+// TestSaveAndLoad verifies entries survive a round trip through the state file
+func TestSaveAndLoad(t *testing.T) {
+	originalBaseDir := repository.BaseDir
+	repository.BaseDir = t.TempDir()
+	defer func() { repository.BaseDir = originalBaseDir }()
+
+	repoPath := filepath.Join(repository.BaseDir, "dotfiles")
+	s, err := Load(repoPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(s.Entries) != 0 {
+		t.Errorf("New state has %d entries, want 0", len(s.Entries))
+	}
+	s.Set(Entry{ExtPath: "/home/test/.bashrc", IntPath: repoPath + "/$HOME/.bashrc", Mode: repository.ModeCopy, Hash: "abc"})
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	delete(states, repoPath)
+	s, err = Load(repoPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	e := s.Get("/home/test/.bashrc")
+	if e == nil || e.Hash != "abc" || e.Mode != repository.ModeCopy {
+		t.Errorf("Get() = %+v, want the saved entry", e)
+	}
+
+	// Only the state file remains, without temporary files
+	entries, err := os.ReadDir(Dir())
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "dotfiles.json" {
+		t.Errorf("State directory contains %v, want only dotfiles.json", entries)
+	}
+}
+
+// TestSaveAllOnlySavesChangedStates verifies state files are written once, by SaveAll
+func TestSaveAllOnlySavesChangedStates(t *testing.T) {
+	originalBaseDir := repository.BaseDir
+	repository.BaseDir = t.TempDir()
+	defer func() { repository.BaseDir = originalBaseDir }()
+	defer Discard()
+
+	changedRepoPath := filepath.Join(repository.BaseDir, "dotfiles")
+	s, err := Load(changedRepoPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	s.Set(Entry{ExtPath: "/home/test/.bashrc", IntPath: changedRepoPath + "/$HOME/.bashrc", Mode: repository.ModeSymlink})
+	if _, err := Load(filepath.Join(repository.BaseDir, "work")); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if _, err := os.Stat(Dir()); !os.IsNotExist(err) {
+		t.Fatal("The state was saved before SaveAll()")
+	}
+
+	if err := SaveAll(); err != nil {
+		t.Fatalf("SaveAll() failed: %v", err)
+	}
+	entries, err := os.ReadDir(Dir())
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "dotfiles.json" {
+		t.Errorf("State directory contains %v, want only dotfiles.json", entries)
+	}
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/andornaut/gog/cmd"
+	"github.com/andornaut/gog/internal/output"
+	"github.com/andornaut/gog/internal/state"
 )
 
 // Execute starts the CLI
 func main() {
-	if err := cmd.Cmd.Execute(); err != nil {
+	err := cmd.Cmd.Execute()
+	// The state of the files that were changed is saved once, even if the command failed
+	saveErr := state.SaveAll()
+	if saveErr != nil {
+		output.EmitError(output.Event{Action: output.ActionError, Reason: saveErr.Error()}, fmt.Sprintf("Error: failed to save state: %s", saveErr))
+	}
+	output.Close(err)
+	if err != nil || saveErr != nil {
 		os.Exit(1)
 	}
 }